@@ -2,18 +2,25 @@ package main
 
 import (
 	"bufio"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"os"
 	"os/exec"
 	"path/filepath"
 	"runtime"
+	"sort"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/daisuke310vvv/sproutee/internal/config"
 	"github.com/daisuke310vvv/sproutee/internal/copy"
+	"github.com/daisuke310vvv/sproutee/internal/vault"
 	"github.com/daisuke310vvv/sproutee/internal/worktree"
 	"github.com/spf13/cobra"
+	"golang.org/x/term"
 )
 
 const (
@@ -41,7 +48,11 @@ var createCmd = &cobra.Command{
 	Short: "Create a new worktree with file copying",
 	Long: `Create a new Git worktree with the specified name. The name will be used
 as both the worktree directory name and the branch name. Files specified in the
-configuration will be automatically copied to the new worktree.`,
+configuration will be automatically copied to the new worktree.
+
+Files are copied from the worktree the command runs in unless --copy-from or
+copy_from names another source: "main" for the main worktree, or a worktree
+name, branch or path.`,
 	Args: cobra.ExactArgs(1),
 	Run: func(cmd *cobra.Command, args []string) {
 		name := args[0]
@@ -53,8 +64,35 @@ configuration will be automatically copied to the new worktree.`,
 			os.Exit(1)
 		}
 
+		// Without a sproutee.json only personal settings, the overlay and the
+		// vault apply
+		cfg, err := config.LoadOptionalConfig()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+
+		copyFrom, _ := cmd.Flags().GetString("copy-from")
+		if copyFrom == "" {
+			copyFrom = cfg.CopyFrom
+		}
+		copySource, err := manager.ResolveCopySource(copyFrom)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		overlayDir, err := resolveOverlayDir(manager, cfg.OverlayDir)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: %v\n", err)
+		}
+		secrets, err := openVault(manager)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: %v\n", err)
+		}
+
 		fmt.Printf("Creating worktree '%s' with branch '%s'...\n", name, branch)
 
+		branchExisted := manager.BranchExists(branch)
 		worktreePath, err := manager.CreateWorktree(name, branch)
 		if err != nil {
 			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
@@ -63,12 +101,30 @@ configuration will be automatically copied to the new worktree.`,
 
 		fmt.Printf("✅ Worktree created successfully at: %s\n", worktreePath)
 
-		fmt.Println("\n📁 Copying configured files...")
-		copyReport, err := copy.FilesToWorktree(manager.RepoRoot, worktreePath)
-		if err != nil {
-			fmt.Fprintf(os.Stderr, "Warning: Failed to copy files: %v\n", err)
+		if copySource == manager.RepoRoot {
+			fmt.Println("\n📁 Copying configured files...")
 		} else {
-			copyReport.PrintSummary()
+			fmt.Printf("\n📁 Copying configured files from %s...\n", copySource)
+		}
+		templateCtx := templateContext(manager, cfg.Ports, worktree.Info{Path: worktreePath, Branch: branch})
+		copyReport := copyWithProgress(func(opts copy.Options) *copy.Report {
+			opts.Template = templateCtx
+			opts.Overlay = overlayDir
+			opts.Verify, _ = cmd.Flags().GetBool("verify")
+			opts.Vault = secrets
+			return copy.FilesFromConfigWithOptions(copySource, worktreePath, cfg, opts)
+		})
+		copyReport.PrintSummary()
+		if reportPath, _ := cmd.Flags().GetString("report"); reportPath != "" {
+			if err := writeReportFile(reportPath, copyReport); err != nil {
+				fmt.Fprintf(os.Stderr, "Warning: %v\n", err)
+			}
+		}
+
+		if failures := copyReport.RequiredFailures(); len(failures) > 0 {
+			fmt.Fprintf(os.Stderr, "\nError: %d required file(s) could not be copied, removing the worktree\n", len(failures))
+			rollbackWorktree(manager, worktreePath, branch, branchExisted)
+			os.Exit(1)
 		}
 
 		// Get flags
@@ -78,6 +134,20 @@ configuration will be automatically copied to the new worktree.`,
 		openAndroidStudio, _ := cmd.Flags().GetBool("android-studio")
 		customDir, _ := cmd.Flags().GetString("dir")
 
+		// Fall back to the configured editor when no editor flag is given
+		if !openCursor && !openVSCode && !openXcode && !openAndroidStudio {
+			switch cfg.Editor {
+			case config.EditorCursor:
+				openCursor = true
+			case config.EditorVSCode:
+				openVSCode = true
+			case config.EditorXcode:
+				openXcode = true
+			case config.EditorAndroidStudio:
+				openAndroidStudio = true
+			}
+		}
+
 		// Determine target path for editor
 		targetPath := worktreePath
 		if customDir != "" {
@@ -96,9 +166,14 @@ configuration will be automatically copied to the new worktree.`,
 			}
 		}
 
+		// Warm dependency directories before init scripts install them
+		if len(cfg.WarmDirs) > 0 {
+			fmt.Println("\n🔥 Warming dependency directories...")
+			copy.PrintWarmSummary(copy.WarmDirs(copySource, worktreePath, cfg))
+		}
+
 		// Execute init scripts if configured
-		cfg, err := config.LoadConfigFromCurrentDir()
-		if err == nil && len(cfg.InitScripts) > 0 {
+		if len(cfg.InitScripts) > 0 {
 			fmt.Printf("\n🔧 Running %d init script(s)...\n", len(cfg.InitScripts))
 			for i, script := range cfg.InitScripts {
 				fmt.Printf("  [%d/%d] %s\n", i+1, len(cfg.InitScripts), script)
@@ -182,30 +257,79 @@ var configInitCmd = &cobra.Command{
 
 		fmt.Printf("Configuration file created: %s\n", configPath)
 		fmt.Println("You can now customize the file to specify which files to copy to new worktrees.")
+		fmt.Printf("Personal settings can go in %s, which should not be committed.\n", config.LocalConfigFileName)
 	},
 }
 
 var configListCmd = &cobra.Command{
 	Use:   "list",
 	Short: "Show configuration",
-	Long:  "Display the current configuration settings.",
-	Run: func(_ *cobra.Command, _ []string) {
-		cfg, err := config.LoadConfigFromCurrentDir()
+	Long: `Display the current configuration settings, merged from the global config file,
+sproutee.json, sproutee.local.json and SPROUTEE_* environment variables.`,
+	Run: func(cmd *cobra.Command, _ []string) {
+		cfg, origins, err := config.LoadConfigWithOrigins()
 		if err != nil {
 			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
 			os.Exit(1)
 		}
 
+		showOrigin, _ := cmd.Flags().GetBool("origin")
+		origin := func(key string) string {
+			source, ok := origins[key]
+			if !showOrigin || !ok {
+				return ""
+			}
+			return "  [" + displayOrigin(source) + "]"
+		}
+
 		fmt.Println("Current configuration:")
 		fmt.Printf("Files to copy: %d\n", len(cfg.CopyFiles))
 		for i, file := range cfg.CopyFiles {
-			fmt.Printf("  %d. %s\n", i+1, file)
+			fmt.Printf("  %d. %s%s\n", i+1, file, origin(fmt.Sprintf("copy_files[%d]", i)))
+		}
+		if cfg.CopyFrom != "" {
+			fmt.Printf("Copy from: %s%s\n", cfg.CopyFrom, origin("copy_from"))
+		}
+		if cfg.OverlayDir != "" {
+			fmt.Printf("Overlay directory: %s%s\n", cfg.OverlayDir, origin("overlay_dir"))
+		}
+		if cfg.Editor != "" {
+			fmt.Printf("Editor: %s%s\n", cfg.Editor, origin("editor"))
+		}
+
+		if cfg.CopyIgnored != nil && cfg.CopyIgnored.Enabled {
+			fmt.Printf("Copy git-ignored files: enabled%s\n", origin("copy_ignored.enabled"))
+			if len(cfg.CopyIgnored.Include) > 0 {
+				fmt.Printf("  Include: %s%s\n", strings.Join(cfg.CopyIgnored.Include, ", "), origin("copy_ignored.include"))
+			}
+			if len(cfg.CopyIgnored.Exclude) > 0 {
+				fmt.Printf("  Exclude: %s%s\n", strings.Join(cfg.CopyIgnored.Exclude, ", "), origin("copy_ignored.exclude"))
+			}
+		}
+
+		if len(cfg.Ports) > 0 {
+			fmt.Println("Ports (base + worktree index):")
+			names := make([]string, 0, len(cfg.Ports))
+			for name := range cfg.Ports {
+				names = append(names, name)
+			}
+			sort.Strings(names)
+			for _, name := range names {
+				fmt.Printf("  %s: %d%s\n", name, cfg.Ports[name], origin("ports."+name))
+			}
+		}
+
+		if len(cfg.WarmDirs) > 0 {
+			fmt.Printf("Warm directories: %d\n", len(cfg.WarmDirs))
+			for i, warm := range cfg.WarmDirs {
+				fmt.Printf("  %d. %s (lockfiles: %s)%s\n", i+1, warm.Dir, strings.Join(warm.Lockfiles, ", "), origin(fmt.Sprintf("warm_dirs[%d]", i)))
+			}
 		}
 
 		if len(cfg.InitScripts) > 0 {
 			fmt.Printf("Init scripts: %d\n", len(cfg.InitScripts))
 			for i, script := range cfg.InitScripts {
-				fmt.Printf("  %d. %s\n", i+1, script)
+				fmt.Printf("  %d. %s%s\n", i+1, script, origin(fmt.Sprintf("init_scripts[%d]", i)))
 			}
 		} else {
 			fmt.Println("Init scripts: (not configured)")
@@ -257,242 +381,1285 @@ var listCmd = &cobra.Command{
 	},
 }
 
+const (
+	exitCodeError   = 1
+	exitCodeSkipped = 2
+
+	defaultTrashRetention = "30d"
+	processStopTimeout    = 5 * time.Second
+)
+
+type worktreeAnalysis struct {
+	Info      worktree.Info
+	Status    *worktree.Status
+	Index     int
+	Reasons   []string
+	Processes []worktree.Process
+}
+
+// IsBusy reports whether running processes use the worktree
+func (a worktreeAnalysis) IsBusy() bool {
+	return len(a.Processes) > 0
+}
+
+// cleanPrompter reads answers for the clean command and decides what happens
+// when no answer can be read
+type cleanPrompter struct {
+	reader  *bufio.Reader
+	yes     bool
+	noInput bool
+	tty     bool
+}
+
+var errNoInput = errors.New("input is disabled")
+
+func (p *cleanPrompter) canPrompt() bool {
+	return !p.noInput && p.tty
+}
+
+func (p *cleanPrompter) readLine() (string, error) {
+	if !p.canPrompt() {
+		return "", errNoInput
+	}
+
+	input, err := p.reader.ReadString('\n')
+	if err != nil && (err != io.EOF || input == "") {
+		return "", fmt.Errorf("failed to read input: %w", err)
+	}
+	return strings.TrimSpace(input), nil
+}
+
+// confirm asks a yes/no question. It returns true without asking when --yes
+// is set and errNoInput when the question cannot be asked.
+func (p *cleanPrompter) confirm(question string) (bool, error) {
+	if p.yes {
+		return true, nil
+	}
+	return p.ask(question)
+}
+
+// ask asks a yes/no question that --yes does not answer, for actions that
+// need an explicit flag or answer.
+func (p *cleanPrompter) ask(question string) (bool, error) {
+	if !p.canPrompt() {
+		return false, errNoInput
+	}
+
+	fmt.Print(question)
+	input, err := p.readLine()
+	if err != nil {
+		return false, err
+	}
+	return strings.ToLower(input) == "y", nil
+}
+
+// isTerminal reports whether the file is attached to a terminal
+func isTerminal(f *os.File) bool {
+	return term.IsTerminal(int(f.Fd())) // #nosec G115
+}
+
 var cleanCmd = &cobra.Command{
-	Use:   "clean",
+	Use:   "clean [name...]",
 	Short: "Clean up worktrees",
-	Long:  "Remove unused or orphaned worktrees. Interactive selection with safety checks for uncommitted changes.",
-	Run: func(cmd *cobra.Command, _ []string) {
+	Long: `Remove unused or orphaned worktrees. Interactive selection with safety checks for uncommitted changes.
+
+Worktrees can also be selected by policy with --merged, --gone, --older-than
+and --match, or by name. Filters combine, and each selected worktree shows why
+it qualified.
+
+Worktrees matching the "protected" patterns in sproutee.json are never offered
+for removal unless --include-protected is given.
+
+On Linux, worktrees used by running processes (shells, editors, dev servers)
+are treated as unsafe and skipped unless --kill is given.
+
+Before a worktree with uncommitted changes is removed, its staged changes,
+unstaged changes and untracked files are archived under refs/sproutee/trash/.
+See 'sproutee trash' and 'sproutee restore'.
+
+Worktrees chosen by name, filters or --all are listed and confirmed before
+they are removed. Use --yes or --no-input to run without prompts, together with
+worktree names, filters or --all to choose the worktrees. Sproutee never
+prompts when stdin is not a terminal. Exit codes: 0 when every selected worktree was
+removed, 1 on errors, 2 when some worktrees were skipped.`,
+	Run: func(cmd *cobra.Command, args []string) {
 		dryRun, _ := cmd.Flags().GetBool("dry-run")
-		force, _ := cmd.Flags().GetBool("force")
+		yes, _ := cmd.Flags().GetBool("yes")
+		noInput, _ := cmd.Flags().GetBool("no-input")
+		all, _ := cmd.Flags().GetBool("all")
+
+		filter, err := cleanFilterFromFlags(cmd)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(exitCodeError)
+		}
+		if all && len(args) > 0 {
+			fmt.Fprintln(os.Stderr, "Error: --all cannot be combined with worktree names")
+			os.Exit(exitCodeError)
+		}
+
+		prompter := &cleanPrompter{
+			reader:  bufio.NewReader(os.Stdin),
+			yes:     yes,
+			noInput: noInput,
+			tty:     isTerminal(os.Stdin),
+		}
 
 		manager, err := worktree.NewManager()
 		if err != nil {
 			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
-			os.Exit(1)
+			os.Exit(exitCodeError)
 		}
 
 		worktrees, err := manager.ListWorktrees()
 		if err != nil {
 			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
-			os.Exit(1)
+			os.Exit(exitCodeError)
 		}
 
-		// Filter out main worktree (repository root)
+		// Filter out the main worktree, which git lists first, and the current one
 		var cleanableWorktrees []worktree.Info
-		for _, wt := range worktrees {
-			if wt.Path != manager.RepoRoot {
+		for i, wt := range worktrees {
+			if i > 0 && wt.Path != manager.RepoRoot {
 				cleanableWorktrees = append(cleanableWorktrees, wt)
 			}
 		}
 
+		if len(args) > 0 {
+			cleanableWorktrees, err = selectWorktreesByName(cleanableWorktrees, args)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+				os.Exit(exitCodeError)
+			}
+		}
+
 		if len(cleanableWorktrees) == 0 {
 			fmt.Println("📁 No additional worktrees found to clean.")
 			return
 		}
 
-		fmt.Printf("🔍 Found %d worktree(s) to analyze:\n\n", len(cleanableWorktrees))
-
-		// Analyze each worktree
-		type worktreeAnalysis struct {
-			Info   worktree.Info
-			Status *worktree.Status
-			Index  int
+		// Interactive selection is only needed when nothing else chose the worktrees
+		needsSelection := !dryRun && len(args) == 0 && filter.IsEmpty() && !all
+		if needsSelection && !prompter.canPrompt() {
+			fmt.Fprintln(os.Stderr, "Error: refusing to prompt for a selection without a terminal; pass worktree names, filters or --all")
+			os.Exit(exitCodeError)
+		}
+		if needsSelection && yes {
+			fmt.Fprintln(os.Stderr, "Error: --yes does not select worktrees; pass worktree names, filters or --all")
+			os.Exit(exitCodeError)
 		}
 
-		var analyses []worktreeAnalysis
-		for i, wt := range cleanableWorktrees {
-			fmt.Printf("Checking %d. %s...\n", i+1, filepath.Base(wt.Path))
+		// A missing sproutee.json only means there are no shared clean
+		// defaults, but an invalid configuration must not drop the protected
+		// patterns
+		cfg, err := config.LoadOptionalConfig()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(exitCodeError)
+		}
+		opts := cleanOptionsFromFlags(cmd, cfg)
 
-			status, err := manager.CheckWorktreeStatus(wt.Path)
-			if err != nil {
-				fmt.Printf("   ❌ Error checking status: %v\n", err)
-				continue
-			}
+		fmt.Printf("🔍 Found %d worktree(s) to analyze:\n\n", len(cleanableWorktrees))
 
-			analyses = append(analyses, worktreeAnalysis{
-				Info:   wt,
-				Status: status,
-				Index:  i + 1,
-			})
+		analyses, protectedCount, analysisErrors := analyzeWorktrees(manager, cleanableWorktrees, filter, opts)
 
-			fmt.Printf("   %s\n", status.GetStatusSummary())
-			if !status.IsClean() && !force {
-				if status.HasStagedChanges || status.HasUnstagedChanges {
-					fmt.Printf("   📝 Changed files: %s\n", strings.Join(status.ChangedFiles, ", "))
-				}
-				if status.HasUntrackedFiles {
-					fmt.Printf("   📄 Untracked files: %s\n", strings.Join(status.UntrackedFiles, ", "))
+		if len(analyses) == 0 {
+			if analysisErrors > 0 {
+				fmt.Println("❌ No worktrees could be analyzed.")
+				os.Exit(exitCodeError)
+			}
+			if protectedCount > 0 {
+				fmt.Println("🔒 All matching worktrees are protected (use --include-protected to remove them).")
+				if len(args) > 0 {
+					os.Exit(exitCodeSkipped)
 				}
+				return
+			}
+			if !filter.IsEmpty() {
+				fmt.Println("📁 No worktrees match the given filters.")
+				return
 			}
-			fmt.Println()
+			fmt.Println("❌ No worktrees could be analyzed.")
+			os.Exit(exitCodeError)
+		}
+		if analysisErrors > 0 {
+			fmt.Printf("❌ %d worktree(s) could not be analyzed.\n\n", analysisErrors)
 		}
 
-		if len(analyses) == 0 {
-			fmt.Println("❌ No worktrees could be analyzed.")
+		if dryRun {
+			fmt.Println("🔍 Dry run - no worktrees will be deleted:")
+			for _, analysis := range analyses {
+				status := "would delete"
+				switch {
+				case analysis.Info.Locked:
+					status = "would be skipped (locked)"
+				case analysis.IsBusy() && !opts.kill:
+					status = "would be skipped (in use)"
+				case analysis.IsBusy():
+					status = fmt.Sprintf("would terminate %d process(es) and delete", len(analysis.Processes))
+				case !analysis.Status.IsClean() && !opts.force && !yes:
+					status = "would require confirmation"
+				}
+				fmt.Printf("   %d. %s - %s", analysis.Index, filepath.Base(analysis.Info.Path), status)
+				if len(analysis.Reasons) > 0 {
+					fmt.Printf(" (%s)", strings.Join(analysis.Reasons, ", "))
+				}
+				fmt.Println()
+			}
+			if analysisErrors > 0 {
+				os.Exit(exitCodeError)
+			}
 			return
 		}
 
-		// Interactive selection
-		if !dryRun {
-			fmt.Println("💡 Select worktrees to delete:")
-			fmt.Println("   - Enter numbers separated by commas (e.g., 1,3,5)")
-			fmt.Println("   - Enter 'clean' to delete only clean worktrees")
-			fmt.Println("   - Enter 'all' to delete all worktrees")
-			fmt.Println("   - Enter 'cancel' to abort")
-
-			if !force {
-				fmt.Println("   ⚠️  Worktrees with uncommitted changes will require confirmation")
+		selected := analyses
+		if needsSelection {
+			selected, err = promptSelection(prompter, analyses, opts.force)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+				os.Exit(exitCodeError)
 			}
-
-			fmt.Print("\nYour choice: ")
-			reader := bufio.NewReader(os.Stdin)
-			input, _ := reader.ReadString('\n')
-			input = strings.TrimSpace(input)
-
-			if input == "cancel" {
+			if len(selected) == 0 {
+				if analysisErrors > 0 {
+					os.Exit(exitCodeError)
+				}
+				return
+			}
+		} else {
+			confirmed, err := confirmSelection(prompter, selected)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+				os.Exit(exitCodeError)
+			}
+			if !confirmed {
 				fmt.Println("❌ Operation cancelled.")
 				return
 			}
+		}
 
-			var selectedIndices []int
-			if input == "all" {
-				for _, analysis := range analyses {
-					selectedIndices = append(selectedIndices, analysis.Index)
-				}
-			} else if input == "clean" {
-				for _, analysis := range analyses {
-					if analysis.Status.IsClean() {
-						selectedIndices = append(selectedIndices, analysis.Index)
-					}
-				}
-				if len(selectedIndices) == 0 {
-					fmt.Println("📁 No clean worktrees found.")
-					return
-				}
-			} else {
-				parts := strings.Split(input, ",")
-				for _, part := range parts {
-					if idx, err := strconv.Atoi(strings.TrimSpace(part)); err == nil {
-						if idx >= 1 && idx <= len(analyses) {
-							selectedIndices = append(selectedIndices, idx)
-						}
-					}
+		summary := removeWorktrees(manager, prompter, selected, opts)
+		summary.failed += analysisErrors
+		purgeTrash(manager, cfg)
+		if len(args) > 0 {
+			// Protected worktrees that were asked for by name count as skipped
+			summary.skipped += protectedCount
+		}
+		if summary.failed > 0 {
+			os.Exit(exitCodeError)
+		}
+		if summary.skipped > 0 {
+			os.Exit(exitCodeSkipped)
+		}
+	},
+}
+
+// selectWorktreesByName keeps the worktrees referenced by the given names and
+// fails when a name does not refer to any worktree
+func selectWorktreesByName(worktrees []worktree.Info, names []string) ([]worktree.Info, error) {
+	var selected []worktree.Info
+	for _, name := range names {
+		found := false
+		for _, wt := range worktrees {
+			if wt.MatchesName(name) {
+				found = true
+				if !containsWorktree(selected, wt) {
+					selected = append(selected, wt)
 				}
 			}
+		}
+		if !found {
+			return nil, fmt.Errorf("no worktree found for '%s'", name)
+		}
+	}
+	return selected, nil
+}
 
-			if len(selectedIndices) == 0 {
-				fmt.Println("❌ No valid worktrees selected.")
-				return
-			}
+func containsWorktree(worktrees []worktree.Info, wt worktree.Info) bool {
+	for _, existing := range worktrees {
+		if existing.Path == wt.Path {
+			return true
+		}
+	}
+	return false
+}
+
+func formatProcesses(processes []worktree.Process) string {
+	names := make([]string, len(processes))
+	for i, p := range processes {
+		names[i] = p.String()
+	}
+	return strings.Join(names, ", ")
+}
 
-			// Process deletions
-			fmt.Printf("\n🗑️  Removing %d worktree(s):\n", len(selectedIndices))
-			for _, idx := range selectedIndices {
-				analysis := analyses[idx-1]
-				fmt.Printf("\n🔄 Processing: %s\n", filepath.Base(analysis.Info.Path))
+// cleanFilterFromFlags builds the policy filter from the clean command flags
+func cleanFilterFromFlags(cmd *cobra.Command) (worktree.Filter, error) {
+	var filter worktree.Filter
 
-				if !analysis.Status.IsClean() && !force {
-					fmt.Printf("⚠️  This worktree has uncommitted changes!\n")
-					fmt.Printf("   %s\n", analysis.Status.GetStatusSummary())
-					fmt.Print("   Continue with deletion? (y/N): ")
+	filter.MergedInto, _ = cmd.Flags().GetString("merged")
+	filter.Gone, _ = cmd.Flags().GetBool("gone")
+	filter.Match, _ = cmd.Flags().GetString("match")
 
-					confirmInput, _ := reader.ReadString('\n')
-					if strings.ToLower(strings.TrimSpace(confirmInput)) != "y" {
-						fmt.Println("   ⏭️  Skipped.")
-						continue
-					}
-				}
+	if olderThan, _ := cmd.Flags().GetString("older-than"); olderThan != "" {
+		age, err := worktree.ParseAge(olderThan)
+		if err != nil {
+			return filter, fmt.Errorf("invalid --older-than value: %w", err)
+		}
+		filter.OlderThan = age
+	}
 
-				var removeErr error
-				if force || !analysis.Status.IsClean() {
-					removeErr = manager.ForceRemoveWorktree(analysis.Info.Path)
-				} else {
-					removeErr = manager.RemoveWorktree(analysis.Info.Path)
-				}
+	filter.AgeBy, _ = cmd.Flags().GetString("age-by")
+	if filter.AgeBy != worktree.AgeByCommit && filter.AgeBy != worktree.AgeByCreated {
+		return filter, fmt.Errorf("invalid --age-by value: %s (expected %s or %s)", filter.AgeBy, worktree.AgeByCommit, worktree.AgeByCreated)
+	}
 
-				if removeErr != nil {
-					fmt.Printf("   ❌ Failed: %v\n", removeErr)
-				} else {
-					fmt.Printf("   ✅ Deleted: %s\n", filepath.Base(analysis.Info.Path))
-				}
+	return filter, nil
+}
+
+// cleanOptionsFromFlags combines the clean command flags with the clean
+// defaults from the configuration file, which may be nil
+func cleanOptionsFromFlags(cmd *cobra.Command, cfg *config.Config) cleanOptions {
+	var opts cleanOptions
+	opts.force, _ = cmd.Flags().GetBool("force")
+	opts.kill, _ = cmd.Flags().GetBool("kill")
+	opts.forceDeleteBranch, _ = cmd.Flags().GetBool("force-delete-branch")
+	opts.includeProtected, _ = cmd.Flags().GetBool("include-protected")
+	noArchive, _ := cmd.Flags().GetBool("no-archive")
+	opts.archive = !noArchive
+
+	if cfg != nil {
+		opts.protected = cfg.Protected
+		if cfg.Trash != nil && cfg.Trash.Disabled && !cmd.Flags().Changed("no-archive") {
+			opts.archive = false
+		}
+		if cfg.Clean != nil {
+			opts.deleteBranch = cfg.Clean.DeleteBranch
+			opts.deleteRemoteBranch = cfg.Clean.DeleteRemoteBranch
+		}
+	}
+	if cmd.Flags().Changed("delete-branch") {
+		opts.deleteBranch, _ = cmd.Flags().GetBool("delete-branch")
+	}
+	if cmd.Flags().Changed("delete-remote-branch") {
+		opts.deleteRemoteBranch, _ = cmd.Flags().GetBool("delete-remote-branch")
+	}
+	if cmd.Flags().Changed("delete-remote-branch") && opts.deleteRemoteBranch {
+		opts.deleteBranch = true
+	}
+	if opts.forceDeleteBranch {
+		opts.deleteBranch = true
+	}
+
+	return opts
+}
+
+// analyzeWorktrees checks the status of each worktree and, when a filter is
+// given, keeps only the worktrees that qualify. Protected worktrees are left
+// out unless explicitly included; their number is returned as well, followed
+// by the number of worktrees that could not be analyzed.
+func analyzeWorktrees(manager *worktree.Manager, worktrees []worktree.Info, filter worktree.Filter, opts cleanOptions) ([]worktreeAnalysis, int, int) {
+	var analyses []worktreeAnalysis
+	protectedCount, errorCount := 0, 0
+	for i, wt := range worktrees {
+		fmt.Printf("Checking %d. %s...\n", i+1, filepath.Base(wt.Path))
+
+		pattern, protected := wt.ProtectedBy(opts.protected)
+		if protected && !opts.includeProtected {
+			fmt.Printf("   🔒 Protected (matches '%s')\n\n", pattern)
+			protectedCount++
+			continue
+		}
+
+		var reasons []string
+		if !filter.IsEmpty() {
+			qualified, why, err := manager.Evaluate(wt, filter)
+			if err != nil {
+				fmt.Printf("   ❌ Error evaluating filters: %v\n\n", err)
+				errorCount++
+				continue
 			}
-		} else {
-			fmt.Println("🔍 Dry run - no worktrees will be deleted:")
-			for _, analysis := range analyses {
-				status := "would delete"
-				if !analysis.Status.IsClean() && !force {
-					status = "would require confirmation"
-				}
-				fmt.Printf("   %d. %s - %s\n", analysis.Index, filepath.Base(analysis.Info.Path), status)
+			if !qualified {
+				fmt.Printf("   ⏭️  Not selected by filters\n\n")
+				continue
 			}
+			reasons = why
 		}
-	},
-}
 
-// openInEditor opens the specified directory in the chosen editor
-func openInEditor(path, editor string) error {
-	var cmd *exec.Cmd
+		status, err := manager.CheckWorktreeStatus(wt.Path)
+		if err != nil {
+			fmt.Printf("   ❌ Error checking status: %v\n\n", err)
+			errorCount++
+			continue
+		}
 
-	switch editor {
-	case "cursor":
-		switch runtime.GOOS {
-		case osDarwin, osWindows, osLinux:
-			cmd = exec.Command("cursor", path)
-		default:
-			return fmt.Errorf("unsupported operating system: %s", runtime.GOOS)
+		processes, err := worktree.FindProcessesUsing(wt.Path)
+		if err != nil {
+			fmt.Printf("   ⚠️  Could not check running processes: %v\n", err)
 		}
-	case "vscode":
-		switch runtime.GOOS {
-		case osDarwin, osWindows, osLinux:
-			cmd = exec.Command("code", path)
-		default:
-			return fmt.Errorf("unsupported operating system: %s", runtime.GOOS)
+
+		analyses = append(analyses, worktreeAnalysis{
+			Info:      wt,
+			Status:    status,
+			Index:     len(analyses) + 1,
+			Reasons:   reasons,
+			Processes: processes,
+		})
+
+		fmt.Printf("   %s\n", status.GetStatusSummary())
+		if protected {
+			fmt.Printf("   🔒 Protected (matches '%s'), included by --include-protected\n", pattern)
 		}
-	case "xcode":
-		switch runtime.GOOS {
-		case osDarwin:
-			cmd = exec.Command("xed", path)
-		default:
-			return fmt.Errorf("Xcode is only available on macOS")
+		if len(reasons) > 0 {
+			fmt.Printf("   🎯 Selected: %s\n", strings.Join(reasons, ", "))
 		}
-	case "android-studio":
-		switch runtime.GOOS {
-		case osDarwin:
-			cmd = exec.Command("open", "-a", "Android Studio", path)
-		case osWindows:
-			cmd = exec.Command("studio", path)
-		case osLinux:
-			cmd = exec.Command("studio.sh", path)
-		default:
-			return fmt.Errorf("unsupported operating system: %s", runtime.GOOS)
+		if len(processes) > 0 {
+			fmt.Printf("   🔥 In use by: %s\n", formatProcesses(processes))
 		}
-	default:
-		return fmt.Errorf("unsupported editor: %s", editor)
+		if !status.IsClean() && !opts.force {
+			if status.HasStagedChanges || status.HasUnstagedChanges {
+				fmt.Printf("   📝 Changed files: %s\n", strings.Join(status.ChangedFiles, ", "))
+			}
+			if status.HasUntrackedFiles {
+				fmt.Printf("   📄 Untracked files: %s\n", strings.Join(status.UntrackedFiles, ", "))
+			}
+		}
+		fmt.Println()
 	}
-
-	return cmd.Start()
+	return analyses, protectedCount, errorCount
 }
 
-// runInitScript executes the specified script in the worktree directory
-func runInitScript(script, workingDir string) error {
-	if strings.TrimSpace(script) == "" {
-		return fmt.Errorf("empty script command")
+// promptSelection asks which of the analyzed worktrees should be deleted
+func promptSelection(prompter *cleanPrompter, analyses []worktreeAnalysis, force bool) ([]worktreeAnalysis, error) {
+	fmt.Println("💡 Select worktrees to delete:")
+	fmt.Println("   - Enter numbers separated by commas (e.g., 1,3,5)")
+	fmt.Println("   - Enter 'clean' to delete only clean worktrees that are not in use")
+	fmt.Println("   - Enter 'all' to delete all worktrees")
+	fmt.Println("   - Enter 'cancel' to abort")
+
+	if !force {
+		fmt.Println("   ⚠️  Worktrees with uncommitted changes will require confirmation")
 	}
+	fmt.Println("   🔥 Worktrees in use by running processes are only removed after terminating them")
 
-	// Use shell to execute the entire command string
-	// This allows for complex commands with pipes, && operators, etc.
-	var cmd *exec.Cmd
-	if runtime.GOOS == "windows" {
-		cmd = exec.Command("cmd", "/C", script)
-	} else {
-		cmd = exec.Command("sh", "-c", script)
+	fmt.Print("\nYour choice: ")
+	input, err := prompter.readLine()
+	if err != nil {
+		return nil, err
 	}
 
-	cmd.Dir = workingDir
-	cmd.Stdout = os.Stdout
-	cmd.Stderr = os.Stderr
+	if input == "cancel" {
+		fmt.Println("❌ Operation cancelled.")
+		return nil, nil
+	}
 
-	return cmd.Run()
+	var selected []worktreeAnalysis
+	switch input {
+	case "all":
+		selected = analyses
+	case "clean":
+		for _, analysis := range analyses {
+			if analysis.Status.IsClean() && !analysis.IsBusy() {
+				selected = append(selected, analysis)
+			}
+		}
+		if len(selected) == 0 {
+			fmt.Println("📁 No clean worktrees found.")
+			return nil, nil
+		}
+	default:
+		parts := strings.Split(input, ",")
+		for _, part := range parts {
+			if idx, err := strconv.Atoi(strings.TrimSpace(part)); err == nil {
+				if idx >= 1 && idx <= len(analyses) {
+					selected = append(selected, analyses[idx-1])
+				}
+			}
+		}
+	}
+
+	if len(selected) == 0 {
+		fmt.Println("❌ No valid worktrees selected.")
+		return nil, nil
+	}
+	return selected, nil
+}
+
+// confirmSelection asks before removing worktrees that were chosen by name,
+// filters or --all. Without a terminal, or with --yes or --no-input, the
+// selection is removed without asking.
+func confirmSelection(prompter *cleanPrompter, selected []worktreeAnalysis) (bool, error) {
+	if prompter.yes || !prompter.canPrompt() {
+		return true, nil
+	}
+
+	fmt.Printf("🗑️  %d worktree(s) will be deleted:\n", len(selected))
+	for _, analysis := range selected {
+		fmt.Printf("   - %s\n", filepath.Base(analysis.Info.Path))
+	}
+	return prompter.confirm("\nDelete these worktrees? (y/N): ")
+}
+
+// cleanOptions controls how selected worktrees are removed
+type cleanOptions struct {
+	force              bool
+	kill               bool
+	archive            bool
+	protected          []string
+	includeProtected   bool
+	deleteBranch       bool
+	deleteRemoteBranch bool
+	forceDeleteBranch  bool
+}
+
+// cleanSummary counts the outcome of a clean run
+type cleanSummary struct {
+	removed int
+	skipped int
+	failed  int
+}
+
+// removeWorktrees deletes the selected worktrees, asking for confirmation
+// before removing worktrees with uncommitted changes unless forced
+func removeWorktrees(manager *worktree.Manager, prompter *cleanPrompter, selected []worktreeAnalysis, opts cleanOptions) cleanSummary {
+	var summary cleanSummary
+
+	fmt.Printf("\n🗑️  Removing %d worktree(s):\n", len(selected))
+	for _, analysis := range selected {
+		fmt.Printf("\n🔄 Processing: %s\n", filepath.Base(analysis.Info.Path))
+
+		// git refuses to remove locked worktrees; check before archiving or
+		// terminating anything
+		if analysis.Info.Locked {
+			fmt.Println("   ⏭️  Skipped: worktree is locked (run 'git worktree unlock' first).")
+			summary.skipped++
+			continue
+		}
+
+		if analysis.IsBusy() && !opts.kill {
+			fmt.Printf("🔥 This worktree is in use by: %s\n", formatProcesses(analysis.Processes))
+
+			confirmed, err := prompter.ask("   Send SIGTERM to these processes and continue? (y/N): ")
+			if err != nil || !confirmed {
+				fmt.Println("   ⏭️  Skipped: worktree is in use (use --kill to terminate its processes).")
+				summary.skipped++
+				continue
+			}
+		}
+
+		if !analysis.Status.IsClean() && !opts.force {
+			fmt.Printf("⚠️  This worktree has uncommitted changes!\n")
+			fmt.Printf("   %s\n", analysis.Status.GetStatusSummary())
+
+			confirmed, err := prompter.confirm("   Continue with deletion? (y/N): ")
+			if err != nil {
+				if errors.Is(err, errNoInput) {
+					fmt.Println("   ⏭️  Skipped: confirmation required (use --force or --yes).")
+				} else {
+					fmt.Printf("\n   ⏭️  Skipped: %v\n", err)
+				}
+				summary.skipped++
+				continue
+			}
+			if !confirmed {
+				fmt.Println("   ⏭️  Skipped.")
+				summary.skipped++
+				continue
+			}
+		}
+
+		if !analysis.Status.IsClean() && opts.archive {
+			entry, err := manager.ArchiveWorktree(analysis.Info)
+			if err != nil {
+				fmt.Printf("   ❌ Failed to archive uncommitted work, not removing (use --no-archive to skip): %v\n", err)
+				summary.failed++
+				continue
+			}
+			fmt.Printf("   🗃️  Archived uncommitted work as %s (restore with 'sproutee restore %s')\n", entry.ID, entry.ID)
+		}
+
+		if analysis.IsBusy() {
+			fmt.Printf("   🛑 Terminating %d process(es)...\n", len(analysis.Processes))
+			if err := worktree.TerminateProcesses(analysis.Info.Path, analysis.Processes, processStopTimeout); err != nil {
+				fmt.Printf("   ❌ Failed: %v\n", err)
+				summary.failed++
+				continue
+			}
+		}
+
+		var removeErr error
+		if opts.force || !analysis.Status.IsClean() {
+			removeErr = manager.ForceRemoveWorktree(analysis.Info.Path)
+		} else {
+			removeErr = manager.RemoveWorktree(analysis.Info.Path)
+		}
+
+		if removeErr != nil {
+			fmt.Printf("   ❌ Failed: %v\n", removeErr)
+			summary.failed++
+			continue
+		}
+
+		fmt.Printf("   ✅ Deleted: %s\n", filepath.Base(analysis.Info.Path))
+		summary.removed++
+
+		if opts.deleteBranch && analysis.Info.Branch != "" {
+			deleteWorktreeBranch(manager, prompter, analysis.Info.Branch, opts, &summary)
+		}
+	}
+
+	fmt.Printf("\n📊 Removed: %d, skipped: %d, failed: %d\n", summary.removed, summary.skipped, summary.failed)
+	return summary
+}
+
+// deleteWorktreeBranch deletes the local branch of a removed worktree. Merged
+// branches are deleted with -d; unmerged branches need --force-delete-branch
+// or an explicit answer before -D is used. Branches checked out in another
+// worktree are never touched.
+func deleteWorktreeBranch(manager *worktree.Manager, prompter *cleanPrompter, branch string, opts cleanOptions, summary *cleanSummary) {
+	checkedOut, err := manager.IsBranchCheckedOut(branch)
+	if err != nil {
+		fmt.Printf("   ❌ Failed to check branch '%s': %v\n", branch, err)
+		summary.failed++
+		return
+	}
+	if checkedOut {
+		fmt.Printf("   ⏭️  Kept branch '%s': checked out in another worktree\n", branch)
+		return
+	}
+
+	// Look up the upstream before the branch and its tracking config are gone
+	upstream := manager.UpstreamBranch(branch)
+
+	merged, err := manager.IsBranchMerged(branch, "HEAD")
+	if err != nil {
+		fmt.Printf("   ❌ Failed to check branch '%s': %v\n", branch, err)
+		summary.failed++
+		return
+	}
+
+	forceDelete := false
+	if !merged {
+		forceDelete = opts.forceDeleteBranch
+		if !forceDelete {
+			if !prompter.canPrompt() {
+				fmt.Printf("   ⏭️  Kept branch '%s': not fully merged (use --force-delete-branch)\n", branch)
+				summary.skipped++
+				return
+			}
+
+			fmt.Printf("   ⚠️  Branch '%s' is not fully merged.\n", branch)
+			confirmed, err := prompter.ask("   Delete it anyway with -D? (y/N): ")
+			if err != nil || !confirmed {
+				fmt.Printf("   ⏭️  Kept branch '%s'\n", branch)
+				summary.skipped++
+				return
+			}
+			forceDelete = true
+		}
+	}
+
+	if err := manager.DeleteBranch(branch, forceDelete); err != nil {
+		fmt.Printf("   ❌ Failed to delete branch '%s': %v\n", branch, err)
+		summary.failed++
+		return
+	}
+	fmt.Printf("   🌿 Deleted branch: %s\n", branch)
+
+	if opts.deleteRemoteBranch && upstream != "" {
+		if err := manager.DeleteRemoteTrackingBranch(upstream); err != nil {
+			fmt.Printf("   ❌ Failed to delete remote-tracking branch '%s': %v\n", upstream, err)
+			summary.failed++
+			return
+		}
+		fmt.Printf("   🌿 Deleted remote-tracking branch: %s\n", upstream)
+	}
+}
+
+var vaultCmd = &cobra.Command{
+	Use:   "vault",
+	Short: "Manage encrypted secret files",
+	Long: `Manage secret files stored encrypted with age under ~/.sproutee/<project>/vault.
+'sproutee create' decrypts every file in the vault into the new worktree.
+
+Files are encrypted to a key kept in the vault directory, or with a passphrase
+when ` + vault.PassphraseEnv + ` is set as the first file is added.`,
+}
+
+var vaultAddCmd = &cobra.Command{
+	Use:   "add <file>...",
+	Short: "Encrypt files into the vault",
+	Long: `Encrypt files of the current worktree into the vault, replacing earlier
+versions. Files are stored under their path relative to the worktree and are
+decrypted to the same path in new worktrees.`,
+	Args: cobra.MinimumNArgs(1),
+	Run: func(_ *cobra.Command, args []string) {
+		manager, err := worktree.NewManager()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		secrets, err := openVault(manager)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+
+		for _, file := range args {
+			relativePath, err := worktreeRelativePath(manager.RepoRoot, file)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+				os.Exit(1)
+			}
+			content, err := os.ReadFile(file)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Error: failed to read %s: %v\n", file, err)
+				os.Exit(1)
+			}
+			if err := secrets.Add(relativePath, content); err != nil {
+				fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+				os.Exit(1)
+			}
+			fmt.Printf("🔐 Stored %s in the vault\n", relativePath)
+		}
+		fmt.Println("ℹ️  The plain-text files were left in place; remove them once the vault works for you.")
+	},
+}
+
+var vaultListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List files in the vault",
+	Long:  "Display the files stored in the vault.",
+	Run: func(_ *cobra.Command, _ []string) {
+		manager, err := worktree.NewManager()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		secrets, err := openVault(manager)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+
+		entries, err := secrets.List()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		if len(entries) == 0 {
+			fmt.Println("🔐 The vault is empty.")
+			return
+		}
+
+		fmt.Printf("Found %d file(s) in %s:\n", len(entries), secrets.Dir())
+		for i, entry := range entries {
+			fmt.Printf("  %d. %s (%s) [%s]\n", i+1, entry.Path, copy.FormatBytes(entry.Size), entry.Modified.Format("2006-01-02 15:04"))
+		}
+	},
+}
+
+var vaultRotateCmd = &cobra.Command{
+	Use:   "rotate",
+	Short: "Re-encrypt the vault with a new key",
+	Long: `Re-encrypt every file in the vault with a newly generated key. Vaults protected
+by a passphrase are re-encrypted with the passphrase in ` + vault.NewPassphraseEnv + `.
+Files already decrypted into worktrees are not changed.`,
+	Run: func(_ *cobra.Command, _ []string) {
+		manager, err := worktree.NewManager()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		secrets, err := openVault(manager)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+
+		rotated, err := secrets.Rotate(os.Getenv(vault.NewPassphraseEnv))
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		fmt.Printf("🔑 Re-encrypted %d file(s)\n", rotated)
+	},
+}
+
+var trashCmd = &cobra.Command{
+	Use:   "trash",
+	Short: "Manage archives of removed worktrees",
+	Long: `Manage the archives sproutee saves before removing worktrees with uncommitted
+changes. Each archive keeps the index, working tree changes and untracked files
+under refs/sproutee/trash/ and can be restored with 'sproutee restore <id>'.`,
+}
+
+var trashListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List archived worktrees",
+	Long:  "Display the archives saved before worktrees with uncommitted changes were removed.",
+	Run: func(_ *cobra.Command, _ []string) {
+		manager, err := worktree.NewManager()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+
+		entries, err := manager.ListTrash()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+
+		if len(entries) == 0 {
+			fmt.Println("🗃️  Trash is empty.")
+			return
+		}
+
+		fmt.Printf("Found %d archive(s):\n", len(entries))
+		for i, entry := range entries {
+			fmt.Printf("  %d. %s", i+1, entry.ID)
+			if entry.Branch != "" {
+				fmt.Printf(" (branch: %s)", entry.Branch)
+			}
+			fmt.Printf(" [%s]\n", entry.CreatedAt.Format("2006-01-02 15:04"))
+		}
+	},
+}
+
+var trashPurgeCmd = &cobra.Command{
+	Use:   "purge",
+	Short: "Delete old archives",
+	Long:  "Delete archives older than the configured trash retention (default 30d).",
+	Run: func(cmd *cobra.Command, _ []string) {
+		manager, err := worktree.NewManager()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+
+		cfg, err := config.LoadOptionalConfig()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		retention, err := trashRetention(cfg)
+		if olderThan, _ := cmd.Flags().GetString("older-than"); olderThan != "" {
+			retention, err = worktree.ParseAge(olderThan)
+		}
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+
+		purged, err := manager.PurgeTrash(retention)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+
+		fmt.Printf("🗑️  Purged %d archive(s).\n", len(purged))
+	},
+}
+
+var restoreCmd = &cobra.Command{
+	Use:   "restore <id>",
+	Short: "Restore an archived worktree",
+	Long: `Recreate a worktree from an archive listed by 'sproutee trash list', with its
+staged changes, unstaged changes and untracked files.`,
+	Args: cobra.ExactArgs(1),
+	Run: func(_ *cobra.Command, args []string) {
+		manager, err := worktree.NewManager()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+
+		worktreePath, err := manager.RestoreTrash(args[0])
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+
+		fmt.Printf("✅ Worktree restored at: %s\n", worktreePath)
+	},
+}
+
+var syncFilesCmd = &cobra.Command{
+	Use:   "sync-files [name...]",
+	Short: "Copy configured files to existing worktrees",
+	Long: `Copy the files configured in copy_files (and copy_ignored) from the copy source
+to existing worktrees again, applying the conflict policy. Pass worktree names
+(directory name, name without timestamp, branch or path) or --all. The source is
+the current worktree unless --copy-from or copy_from names another one. The
+main worktree is only synced when it is named; --all leaves it out.
+
+With --check nothing is copied: the checksums of the copied files are compared
+with the sources and the files that differ or are missing are listed. The
+command then exits with status 1 when any worktree has drifted.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		all, _ := cmd.Flags().GetBool("all")
+		check, _ := cmd.Flags().GetBool("check")
+		verify, _ := cmd.Flags().GetBool("verify")
+
+		if all == (len(args) > 0) {
+			fmt.Fprintln(os.Stderr, "Error: pass worktree names or --all")
+			os.Exit(1)
+		}
+
+		cfg, err := config.LoadConfigFromCurrentDir()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+
+		manager, err := worktree.NewManager()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+
+		worktrees, err := manager.ListWorktrees()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+
+		copyFrom, _ := cmd.Flags().GetString("copy-from")
+		if copyFrom == "" {
+			copyFrom = cfg.CopyFrom
+		}
+		copySource, err := manager.ResolveCopySource(copyFrom)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+
+		// git lists the main worktree first; --all never overwrites its files
+		var targets []worktree.Info
+		for i, wt := range worktrees {
+			if wt.Path != copySource && (i > 0 || !all) {
+				targets = append(targets, wt)
+			}
+		}
+		if !all {
+			targets, err = selectWorktreesByName(targets, args)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+				os.Exit(1)
+			}
+		}
+
+		if len(targets) == 0 {
+			fmt.Println("📁 No additional worktrees found to sync.")
+			return
+		}
+
+		if check {
+			if checkDrift(manager, cfg, copySource, targets) {
+				os.Exit(1)
+			}
+			return
+		}
+
+		failed := false
+		var reports []*copy.Report
+		for _, wt := range targets {
+			fmt.Printf("\n🔄 Syncing files to %s (branch: %s)...\n", wt.Path, wt.Branch)
+			templateCtx := templateContext(manager, cfg.Ports, wt)
+			report := copyWithProgress(func(opts copy.Options) *copy.Report {
+				opts.Template = templateCtx
+				opts.Verify = verify
+				return copy.FilesFromConfigWithOptions(copySource, wt.Path, cfg, opts)
+			})
+			report.PrintSummary()
+			reports = append(reports, report)
+			if report.FailureCount > 0 {
+				failed = true
+			}
+		}
+
+		if reportPath, _ := cmd.Flags().GetString("report"); reportPath != "" {
+			if err := writeReportFile(reportPath, reports); err != nil {
+				fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+				os.Exit(1)
+			}
+		}
+
+		if failed {
+			os.Exit(1)
+		}
+	},
+}
+
+// trashRetention returns how long archives are kept, from the configuration
+// file when set
+func trashRetention(cfg *config.Config) (time.Duration, error) {
+	retention := defaultTrashRetention
+	if cfg != nil && cfg.Trash != nil && cfg.Trash.Retention != "" {
+		retention = cfg.Trash.Retention
+	}
+
+	duration, err := worktree.ParseAge(retention)
+	if err != nil {
+		return 0, fmt.Errorf("invalid trash retention: %w", err)
+	}
+	return duration, nil
+}
+
+// purgeTrash deletes archives that outlived the retention period
+func purgeTrash(manager *worktree.Manager, cfg *config.Config) {
+	retention, err := trashRetention(cfg)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: %v\n", err)
+		return
+	}
+
+	purged, err := manager.PurgeTrash(retention)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: Failed to purge old archives: %v\n", err)
+		return
+	}
+	if len(purged) > 0 {
+		fmt.Printf("🗃️  Purged %d archive(s) past the trash retention\n", len(purged))
+	}
+}
+
+// openInEditor opens the specified directory in the chosen editor
+func openInEditor(path, editor string) error {
+	var cmd *exec.Cmd
+
+	switch editor {
+	case "cursor":
+		switch runtime.GOOS {
+		case osDarwin, osWindows, osLinux:
+			cmd = exec.Command("cursor", path)
+		default:
+			return fmt.Errorf("unsupported operating system: %s", runtime.GOOS)
+		}
+	case "vscode":
+		switch runtime.GOOS {
+		case osDarwin, osWindows, osLinux:
+			cmd = exec.Command("code", path)
+		default:
+			return fmt.Errorf("unsupported operating system: %s", runtime.GOOS)
+		}
+	case "xcode":
+		switch runtime.GOOS {
+		case osDarwin:
+			cmd = exec.Command("xed", path)
+		default:
+			return fmt.Errorf("Xcode is only available on macOS")
+		}
+	case "android-studio":
+		switch runtime.GOOS {
+		case osDarwin:
+			cmd = exec.Command("open", "-a", "Android Studio", path)
+		case osWindows:
+			cmd = exec.Command("studio", path)
+		case osLinux:
+			cmd = exec.Command("studio.sh", path)
+		default:
+			return fmt.Errorf("unsupported operating system: %s", runtime.GOOS)
+		}
+	default:
+		return fmt.Errorf("unsupported editor: %s", editor)
+	}
+
+	return cmd.Start()
+}
+
+// runInitScript executes the specified script in the worktree directory
+func runInitScript(script, workingDir string) error {
+	if strings.TrimSpace(script) == "" {
+		return fmt.Errorf("empty script command")
+	}
+
+	// Use shell to execute the entire command string
+	// This allows for complex commands with pipes, && operators, etc.
+	var cmd *exec.Cmd
+	if runtime.GOOS == "windows" {
+		cmd = exec.Command("cmd", "/C", script)
+	} else {
+		cmd = exec.Command("sh", "-c", script)
+	}
+
+	cmd.Dir = workingDir
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+
+	return cmd.Run()
+}
+
+// checkDrift prints the copied files that differ from their source in each
+// worktree and reports whether any did.
+func checkDrift(manager *worktree.Manager, cfg *config.Config, source string, targets []worktree.Info) bool {
+	drifted := false
+	for _, wt := range targets {
+		drifts, err := copy.CheckDrift(source, wt.Path, cfg, templateContext(manager, cfg.Ports, wt))
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %s: %v\n", wt.Path, err)
+			drifted = true
+			continue
+		}
+
+		fmt.Printf("\n🔍 %s (branch: %s)\n", wt.Path, wt.Branch)
+		inSync := 0
+		for _, drift := range drifts {
+			relativePath, err := filepath.Rel(wt.Path, drift.TargetPath)
+			if err != nil {
+				relativePath = drift.TargetPath
+			}
+
+			switch drift.Status {
+			case copy.DriftInSync:
+				inSync++
+			case copy.DriftModified:
+				fmt.Printf("   ✏️  %s differs from the source\n", relativePath)
+				drifted = true
+			case copy.DriftMissing:
+				fmt.Printf("   ❓ %s is missing\n", relativePath)
+				drifted = true
+			}
+		}
+		fmt.Printf("   ✅ %d of %d file(s) in sync\n", inSync, len(drifts))
+	}
+	return drifted
+}
+
+// templateContext describes a worktree for rendering template entries. Ports
+// are offset by the worktree index, which falls back to 0 when it cannot be
+// allocated.
+func templateContext(manager *worktree.Manager, ports map[string]int, wt worktree.Info) *copy.TemplateContext {
+	index, err := manager.WorktreeIndex(wt.Path)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: Failed to allocate worktree index: %v\n", err)
+	}
+	return copy.NewTemplateContext(worktree.WorktreeName(wt.Path), wt.Branch, wt.Path, index, ports)
+}
+
+// writeReportFile saves copy reports as JSON for scripts and CI.
+func writeReportFile(reportPath string, report any) error {
+	data, err := json.MarshalIndent(report, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode copy report: %w", err)
+	}
+	if err := os.WriteFile(reportPath, append(data, '\n'), 0o600); err != nil {
+		return fmt.Errorf("failed to write copy report: %w", err)
+	}
+	return nil
+}
+
+// displayOrigin shortens the source of a configuration value: repository
+// config files by name and other files relative to the home directory.
+func displayOrigin(source string) string {
+	if strings.HasPrefix(source, "$") {
+		return source
+	}
+	if name := filepath.Base(source); name == config.ConfigFileName || name == config.LocalConfigFileName {
+		return name
+	}
+	if home, err := os.UserHomeDir(); err == nil && strings.HasPrefix(source, home+string(filepath.Separator)) {
+		return "~" + source[len(home):]
+	}
+	return source
+}
+
+// openVault opens the project's vault, protected by the passphrase in the
+// environment when it has no key.
+func openVault(manager *worktree.Manager) (*vault.Vault, error) {
+	projectDir, err := manager.ProjectDir()
+	if err != nil {
+		return nil, fmt.Errorf("failed to locate vault: %w", err)
+	}
+	return vault.Open(filepath.Join(projectDir, vault.DirName), os.Getenv(vault.PassphraseEnv)), nil
+}
+
+// worktreeRelativePath returns the slash-separated path of file relative to
+// the worktree root, failing for files outside it.
+func worktreeRelativePath(root, file string) (string, error) {
+	absPath, err := filepath.Abs(file)
+	if err != nil {
+		return "", fmt.Errorf("failed to get absolute path: %w", err)
+	}
+	if resolved, err := filepath.EvalSymlinks(filepath.Dir(absPath)); err == nil {
+		absPath = filepath.Join(resolved, filepath.Base(absPath))
+	}
+	relativePath, err := filepath.Rel(root, absPath)
+	if err != nil || config.EscapesRoot(relativePath) {
+		return "", fmt.Errorf("%s is outside the worktree", file)
+	}
+	return filepath.ToSlash(relativePath), nil
+}
+
+// resolveOverlayDir returns the configured overlay directory with "~"
+// expanded, or the project's default one.
+func resolveOverlayDir(manager *worktree.Manager, configured string) (string, error) {
+	if configured != "" {
+		return config.ExpandHome(configured)
+	}
+	overlayDir, err := manager.DefaultOverlayDir()
+	if err != nil {
+		return "", fmt.Errorf("failed to locate overlay directory: %w", err)
+	}
+	return overlayDir, nil
+}
+
+// rollbackWorktree removes a worktree that was just created, and its branch
+// when the branch was created along with it.
+func rollbackWorktree(manager *worktree.Manager, worktreePath, branch string, branchExisted bool) {
+	if err := manager.ForceRemoveWorktree(worktreePath); err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: Failed to remove worktree: %v\n", err)
+		return
+	}
+	fmt.Printf("🗑️  Removed worktree: %s\n", worktreePath)
+
+	if branchExisted {
+		return
+	}
+	if err := manager.DeleteBranch(branch, true); err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: Failed to delete branch: %v\n", err)
+		return
+	}
+	fmt.Printf("🗑️  Deleted branch: %s\n", branch)
+}
+
+// copyWithProgress runs a copy and draws a progress bar on stderr while it
+// runs, when stderr is a terminal.
+func copyWithProgress(run func(copy.Options) *copy.Report) *copy.Report {
+	if !isTerminal(os.Stderr) {
+		return run(copy.Options{})
+	}
+
+	progress := make(chan copy.Progress)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for p := range progress {
+			renderProgress(p)
+		}
+		fmt.Fprint(os.Stderr, "\r\033[K")
+	}()
+
+	report := run(copy.Options{Progress: progress})
+	close(progress)
+	<-done
+	return report
+}
+
+// renderProgress redraws the progress bar of a running copy
+func renderProgress(p copy.Progress) {
+	const width = 30
+
+	filled := width
+	switch {
+	case p.TotalBytes > 0:
+		filled = int(int64(width) * p.BytesDone / p.TotalBytes)
+	case p.TotalFiles > 0:
+		filled = width * p.FilesDone / p.TotalFiles
+	}
+
+	fmt.Fprintf(os.Stderr, "\r\033[K   [%s%s] %d/%d files, %s/%s",
+		strings.Repeat("█", filled), strings.Repeat("░", width-filled),
+		p.FilesDone, p.TotalFiles, copy.FormatBytes(p.BytesDone), copy.FormatBytes(p.TotalBytes))
 }
 
 func init() {
@@ -501,12 +1668,46 @@ func init() {
 	createCmd.Flags().Bool("xcode", false, "Automatically open the created worktree in Xcode (macOS only)")
 	createCmd.Flags().Bool("android-studio", false, "Automatically open the created worktree in Android Studio")
 	createCmd.Flags().String("dir", "", "Specify directory to open in editor (absolute or relative path)")
+	createCmd.Flags().String("report", "", "Write the copy report as JSON to this file")
+	createCmd.Flags().Bool("verify", false, "Check the SHA-256 checksum of every copied file against its source")
+	createCmd.Flags().String("copy-from", "", "Copy files from this worktree (name, branch or path), 'main' or 'current' (default: copy_from or current)")
 
 	cleanCmd.Flags().Bool("dry-run", false, "Show what would be deleted without actually deleting")
 	cleanCmd.Flags().Bool("force", false, "Force deletion without confirmation for worktrees with uncommitted changes")
+	cleanCmd.Flags().String("merged", "", "Select worktrees whose branch is fully merged (use --merged=<branch>, defaults to HEAD)")
+	cleanCmd.Flags().Lookup("merged").NoOptDefVal = "HEAD"
+	cleanCmd.Flags().Bool("gone", false, "Select worktrees whose upstream branch has been deleted")
+	cleanCmd.Flags().String("older-than", "", "Select worktrees older than the given age (e.g. 14d, 2w, 36h)")
+	cleanCmd.Flags().String("age-by", worktree.AgeByCommit, "Measure age by last 'commit' or 'created' time")
+	cleanCmd.Flags().String("match", "", "Select worktrees whose branch or name matches the pattern (e.g. 'feature/*')")
+	cleanCmd.Flags().Bool("kill", false, "Send SIGTERM to processes using a worktree before removing it (Linux only)")
+	cleanCmd.Flags().Bool("no-archive", false, "Do not archive uncommitted work before removing dirty worktrees")
+	cleanCmd.Flags().Bool("include-protected", false, "Allow removing worktrees that match the configured protected patterns")
+	cleanCmd.Flags().Bool("delete-branch", false, "Delete the local branch of each removed worktree (merged branches only)")
+	cleanCmd.Flags().Bool("force-delete-branch", false, "Delete unmerged branches with -D without asking (implies --delete-branch)")
+	cleanCmd.Flags().Bool("delete-remote-branch", false, "Also delete the remote-tracking branch of deleted branches (implies --delete-branch)")
+	cleanCmd.Flags().Bool("all", false, "Select every worktree (protected worktrees still need --include-protected)")
+	cleanCmd.Flags().BoolP("yes", "y", false, "Answer yes to all prompts")
+	cleanCmd.Flags().Bool("no-input", false, "Never prompt; worktrees that need confirmation are skipped")
 
 	listCmd.Flags().BoolP("path", "p", false, "Show only paths of worktrees")
 
+	syncFilesCmd.Flags().Bool("all", false, "Sync every worktree except the main worktree and the copy source")
+	syncFilesCmd.Flags().String("report", "", "Write the copy reports of all worktrees as a JSON array to this file")
+	syncFilesCmd.Flags().Bool("verify", false, "Check the SHA-256 checksum of every copied file against its source")
+	syncFilesCmd.Flags().String("copy-from", "", "Copy files from this worktree (name, branch or path), 'main' or 'current' (default: copy_from or current)")
+	syncFilesCmd.Flags().Bool("check", false, "Only compare checksums and list files that differ from the source")
+
+	trashPurgeCmd.Flags().String("older-than", "", "Purge entries older than this age instead of the configured retention")
+	trashCmd.AddCommand(trashListCmd)
+	trashCmd.AddCommand(trashPurgeCmd)
+
+	vaultCmd.AddCommand(vaultAddCmd)
+	vaultCmd.AddCommand(vaultListCmd)
+	vaultCmd.AddCommand(vaultRotateCmd)
+
+	configListCmd.Flags().Bool("origin", false, "Show the config file or environment variable each value comes from")
+
 	configCmd.AddCommand(configInitCmd)
 	configCmd.AddCommand(configListCmd)
 
@@ -514,6 +1715,10 @@ func init() {
 	rootCmd.AddCommand(configCmd)
 	rootCmd.AddCommand(listCmd)
 	rootCmd.AddCommand(cleanCmd)
+	rootCmd.AddCommand(trashCmd)
+	rootCmd.AddCommand(restoreCmd)
+	rootCmd.AddCommand(syncFilesCmd)
+	rootCmd.AddCommand(vaultCmd)
 }
 
 func main() {
@@ -2,8 +2,15 @@ module github.com/daisuke310vvv/sproutee
 
 go 1.24.4
 
+require (
+	filippo.io/age v1.0.0
+	github.com/spf13/cobra v1.9.1
+	golang.org/x/sys v0.35.0
+	golang.org/x/term v0.34.0
+)
+
 require (
 	github.com/inconshreveable/mousetrap v1.1.0 // indirect
-	github.com/spf13/cobra v1.9.1 // indirect
 	github.com/spf13/pflag v1.0.6 // indirect
+	golang.org/x/crypto v0.41.0 // indirect
 )
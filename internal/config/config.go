@@ -2,21 +2,92 @@ package config
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"os"
+	"os/exec"
+	"path"
 	"path/filepath"
+	"strings"
+
+	"github.com/daisuke310vvv/sproutee/internal/dotenv"
 )
 
 const ConfigFileName = "sproutee.json"
 
+// ErrConfigNotFound is returned when no sproutee.json exists in the current
+// directory or any of its parents.
+var ErrConfigNotFound = errors.New("configuration file '" + ConfigFileName + "' not found")
+
+// Editors that create opens new worktrees in.
+const (
+	EditorCursor        = "cursor"
+	EditorVSCode        = "vscode"
+	EditorXcode         = "xcode"
+	EditorAndroidStudio = "android-studio"
+)
+
 type Config struct {
-	CopyFiles   []string `json:"copy_files"`
-	InitScripts []string `json:"init_scripts,omitempty"`
+	CopyFiles              []CopyEntry        `json:"copy_files"`
+	ExcludeFiles           []string           `json:"exclude_files,omitempty"`
+	AllowedExternalSources []string           `json:"allowed_external_sources,omitempty"`
+	ConflictPolicy         string             `json:"conflict_policy,omitempty"`
+	Preserve               *PreserveConfig    `json:"preserve,omitempty"`
+	CopyWorkers            int                `json:"copy_workers,omitempty"`
+	VerifyCopies           bool               `json:"verify_copies,omitempty"`
+	CopyFrom               string             `json:"copy_from,omitempty"`
+	OverlayDir             string             `json:"overlay_dir,omitempty"`
+	Editor                 string             `json:"editor,omitempty"`
+	Ports                  map[string]int     `json:"ports,omitempty"`
+	CopyIgnored            *CopyIgnoredConfig `json:"copy_ignored,omitempty"`
+	WarmDirs               []WarmDir          `json:"warm_dirs,omitempty"`
+	InitScripts            []string           `json:"init_scripts,omitempty"`
+	Clean                  *CleanConfig       `json:"clean,omitempty"`
+	Protected              []string           `json:"protected,omitempty"`
+	Trash                  *TrashConfig       `json:"trash,omitempty"`
+}
+
+// CopyIgnoredConfig enables copying files that Git ignores but that are
+// present in the source worktree.
+type CopyIgnoredConfig struct {
+	Enabled  bool     `json:"enabled"`
+	Include  []string `json:"include,omitempty"`
+	Exclude  []string `json:"exclude,omitempty"`
+	SkipDirs []string `json:"skip_dirs,omitempty"`
+}
+
+// WarmDir is a dependency directory, such as node_modules, that is copied to
+// new worktrees when its lockfiles are identical in both worktrees, so that
+// init scripts find it already installed.
+type WarmDir struct {
+	Dir       string   `json:"dir"`
+	Lockfiles []string `json:"lockfiles"`
+	Mode      string   `json:"mode,omitempty"`
+}
+
+// PreserveConfig selects the metadata kept when files are copied or cloned.
+type PreserveConfig struct {
+	Symlinks bool `json:"symlinks,omitempty"`
+	Times    bool `json:"times,omitempty"`
+	Xattrs   bool `json:"xattrs,omitempty"`
+	DirModes bool `json:"dir_modes,omitempty"`
+}
+
+// CleanConfig holds defaults for the clean command.
+type CleanConfig struct {
+	DeleteBranch       bool `json:"delete_branch,omitempty"`
+	DeleteRemoteBranch bool `json:"delete_remote_branch,omitempty"`
+}
+
+// TrashConfig controls the archives saved before forced removals.
+type TrashConfig struct {
+	Disabled  bool   `json:"disabled,omitempty"`
+	Retention string `json:"retention,omitempty"`
 }
 
 func DefaultConfig() *Config {
 	return &Config{
-		CopyFiles: []string{},
+		CopyFiles: []CopyEntry{},
 	}
 }
 
@@ -24,6 +95,89 @@ func (c *Config) Validate() error {
 	if c.CopyFiles == nil {
 		return fmt.Errorf("copy_files field is required")
 	}
+	if err := validateConflictPolicy(c.ConflictPolicy); err != nil {
+		return fmt.Errorf("invalid conflict_policy: %w", err)
+	}
+	if c.CopyWorkers < 0 {
+		return fmt.Errorf("copy_workers must not be negative")
+	}
+	for _, entry := range c.CopyFiles {
+		if entry.From == "" {
+			return fmt.Errorf("copy_files entry is missing 'from'")
+		}
+		if _, err := path.Match(strings.TrimPrefix(entry.From, "!"), ""); err != nil {
+			return fmt.Errorf("invalid copy_files pattern '%s': %w", entry.From, err)
+		}
+		if err := validateCopyMode(entry.Mode); err != nil {
+			return fmt.Errorf("invalid copy_files entry '%s': %w", entry.From, err)
+		}
+		if err := validateConflictPolicy(entry.Conflict); err != nil {
+			return fmt.Errorf("invalid copy_files entry '%s': %w", entry.From, err)
+		}
+		if entry.Template && entry.Mode != "" && entry.Mode != CopyModeCopy {
+			return fmt.Errorf("invalid copy_files entry '%s': templates can only be copied, not linked or cloned", entry.From)
+		}
+		if len(entry.Env) > 0 && entry.Mode != "" && entry.Mode != CopyModeCopy {
+			return fmt.Errorf("invalid copy_files entry '%s': env overrides can only be applied to copies, not links or clones", entry.From)
+		}
+		for key := range entry.Env {
+			if !dotenv.IsValidKey(key) {
+				return fmt.Errorf("invalid copy_files entry '%s': invalid env key '%s'", entry.From, key)
+			}
+		}
+		if entry.Required && entry.Optional {
+			return fmt.Errorf("invalid copy_files entry '%s': cannot be both required and optional", entry.From)
+		}
+		if entry.To != "" && (IsExternalPath(entry.To) || EscapesRoot(entry.To)) {
+			return fmt.Errorf("invalid copy_files entry '%s': 'to' must be a relative path inside the worktree", entry.From)
+		}
+		if strings.HasPrefix(entry.From, "!") {
+			if entry.To != "" {
+				return fmt.Errorf("invalid copy_files entry '%s': negations cannot have 'to'", entry.From)
+			}
+			continue
+		}
+		if EscapesRoot(entry.From) {
+			return fmt.Errorf("invalid copy_files entry '%s': paths must not leave the repository with '..'; use an absolute path listed in allowed_external_sources", entry.From)
+		}
+		if IsExternalPath(entry.From) {
+			source, err := ExpandHome(entry.From)
+			if err != nil {
+				return err
+			}
+			if !filepath.IsAbs(source) || !c.ExternalSourceAllowed(source) {
+				return fmt.Errorf("copy_files entry '%s' is outside the repository and not listed in allowed_external_sources", entry.From)
+			}
+		}
+	}
+	if c.OverlayDir != "" && !IsExternalPath(c.OverlayDir) {
+		return fmt.Errorf("overlay_dir must be an absolute path or start with '~': %s", c.OverlayDir)
+	}
+	switch c.Editor {
+	case "", EditorCursor, EditorVSCode, EditorXcode, EditorAndroidStudio:
+	default:
+		return fmt.Errorf("unknown editor '%s' (expected %s, %s, %s or %s)", c.Editor, EditorCursor, EditorVSCode, EditorXcode, EditorAndroidStudio)
+	}
+	for _, warm := range c.WarmDirs {
+		if err := warm.validate(); err != nil {
+			return fmt.Errorf("invalid warm_dirs entry '%s': %w", warm.Dir, err)
+		}
+	}
+	for name, port := range c.Ports {
+		if port <= 0 || port > 65535 {
+			return fmt.Errorf("invalid port '%s': %d", name, port)
+		}
+	}
+	for _, pattern := range c.ExcludeFiles {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return fmt.Errorf("invalid exclude_files pattern '%s': %w", pattern, err)
+		}
+	}
+	for _, pattern := range c.Protected {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return fmt.Errorf("invalid protected pattern '%s': %w", pattern, err)
+		}
+	}
 	return nil
 }
 
@@ -46,7 +200,7 @@ func FindConfigFile(startDir string) (string, error) {
 		currentDir = parentDir
 	}
 
-	return "", fmt.Errorf("configuration file '%s' not found", ConfigFileName)
+	return "", ErrConfigNotFound
 }
 
 func LoadConfig(configPath string) (*Config, error) {
@@ -67,18 +221,60 @@ func LoadConfig(configPath string) (*Config, error) {
 	return &config, nil
 }
 
+// LoadConfigFromCurrentDir loads the layered configuration of the
+// repository containing the current directory.
 func LoadConfigFromCurrentDir() (*Config, error) {
+	config, _, err := LoadConfigWithOrigins()
+	return config, err
+}
+
+// LoadConfigWithOrigins is LoadConfigFromCurrentDir that also reports where
+// each value came from.
+func LoadConfigWithOrigins() (*Config, Origins, error) {
 	wd, err := os.Getwd()
 	if err != nil {
-		return nil, fmt.Errorf("failed to get current directory: %w", err)
+		return nil, nil, fmt.Errorf("failed to get current directory: %w", err)
 	}
 
 	configPath, err := FindConfigFile(wd)
 	if err != nil {
+		return nil, nil, err
+	}
+
+	return LoadLayeredConfig(configPath)
+}
+
+// LoadOptionalConfig is LoadConfigFromCurrentDir for repositories that may
+// have no sproutee.json. The global config, sproutee.local.json at the root of
+// the repository and the environment then apply to the default configuration.
+func LoadOptionalConfig() (*Config, error) {
+	wd, err := os.Getwd()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get current directory: %w", err)
+	}
+
+	required := true
+	configPath, err := FindConfigFile(wd)
+	if errors.Is(err, ErrConfigNotFound) {
+		configPath, required = filepath.Join(repositoryRoot(wd), ConfigFileName), false
+	} else if err != nil {
 		return nil, err
 	}
 
-	return LoadConfig(configPath)
+	config, _, err := loadLayers(configPath, required)
+	return config, err
+}
+
+// repositoryRoot returns the top level of the git worktree containing dir, or
+// dir itself outside a repository.
+func repositoryRoot(dir string) string {
+	cmd := exec.Command("git", "rev-parse", "--show-toplevel")
+	cmd.Dir = dir
+	output, err := cmd.Output()
+	if err != nil {
+		return dir
+	}
+	return strings.TrimSpace(string(output))
 }
 
 func SaveConfig(config *Config, configPath string) error {
@@ -106,3 +302,20 @@ func CreateDefaultConfigFile(configPath string) error {
 	defaultConfig := DefaultConfig()
 	return SaveConfig(defaultConfig, configPath)
 }
+
+func (w WarmDir) validate() error {
+	paths := append([]string{w.Dir}, w.Lockfiles...)
+	for _, p := range paths {
+		if p == "" || IsExternalPath(p) || EscapesRoot(p) || path.Clean(filepath.ToSlash(p)) == "." {
+			return fmt.Errorf("'%s' must be a relative path inside the worktree", p)
+		}
+	}
+	if len(w.Lockfiles) == 0 {
+		return fmt.Errorf("at least one lockfile is required")
+	}
+	switch w.Mode {
+	case "", CopyModeClone, CopyModeHardlink, CopyModeCopy:
+		return nil
+	}
+	return fmt.Errorf("unknown mode '%s' (expected %s, %s or %s)", w.Mode, CopyModeClone, CopyModeHardlink, CopyModeCopy)
+}
@@ -1,8 +1,11 @@
 package config
 
 import (
+	"encoding/json"
+	"errors"
 	"os"
 	"path/filepath"
+	"reflect"
 	"testing"
 )
 
@@ -32,7 +35,7 @@ func TestConfigValidate(t *testing.T) {
 		{
 			name: "valid config",
 			config: &Config{
-				CopyFiles: []string{".env", "docker-compose.yml"},
+				CopyFiles: CopyEntries(".env", "docker-compose.yml"),
 			},
 			wantErr: false,
 		},
@@ -43,10 +46,160 @@ func TestConfigValidate(t *testing.T) {
 			},
 			wantErr: true,
 		},
+		{
+			name: "invalid protected pattern",
+			config: &Config{
+				CopyFiles: []CopyEntry{},
+				Protected: []string{"release/["},
+			},
+			wantErr: true,
+		},
+		{
+			name: "unknown copy mode",
+			config: &Config{
+				CopyFiles: []CopyEntry{{From: "fixtures", Mode: "move"}},
+			},
+			wantErr: true,
+		},
+		{
+			name: "target outside the worktree",
+			config: &Config{
+				CopyFiles: []CopyEntry{{From: ".env", To: "../.env"}},
+			},
+			wantErr: true,
+		},
+		{
+			name: "external source not allowed",
+			config: &Config{
+				CopyFiles: []CopyEntry{{From: "~/.secrets/app.env"}},
+			},
+			wantErr: true,
+		},
+		{
+			name: "allowed external source",
+			config: &Config{
+				CopyFiles:              []CopyEntry{{From: "~/.secrets/app.env", To: ".env"}},
+				AllowedExternalSources: []string{"~/.secrets"},
+			},
+			wantErr: false,
+		},
+		{
+			name: "unknown conflict policy",
+			config: &Config{
+				CopyFiles:      []CopyEntry{},
+				ConflictPolicy: "merge",
+			},
+			wantErr: true,
+		},
+		{
+			name: "required and optional entry",
+			config: &Config{
+				CopyFiles: []CopyEntry{{From: ".env", Required: true, Optional: true}},
+			},
+			wantErr: true,
+		},
+		{
+			name: "source leaving the repository",
+			config: &Config{
+				CopyFiles:              []CopyEntry{{From: "../../.ssh/id_rsa"}},
+				AllowedExternalSources: []string{"/"},
+			},
+			wantErr: true,
+		},
+		{
+			name: "absolute source not allowed",
+			config: &Config{
+				CopyFiles: []CopyEntry{{From: "/etc/passwd"}},
+			},
+			wantErr: true,
+		},
+		{
+			name: "negation is not a source",
+			config: &Config{
+				CopyFiles: []CopyEntry{{From: "fixtures"}, {From: "!fixtures/../fixtures/secret"}},
+			},
+			wantErr: false,
+		},
+		{
+			name: "linked template",
+			config: &Config{
+				CopyFiles: []CopyEntry{{From: ".env", Template: true, Mode: CopyModeSymlink}},
+			},
+			wantErr: true,
+		},
+		{
+			name: "env overrides",
+			config: &Config{
+				CopyFiles: []CopyEntry{{From: ".env", Env: map[string]string{"PORT": "{{.Ports.web}}"}}},
+			},
+			wantErr: false,
+		},
+		{
+			name: "invalid env key",
+			config: &Config{
+				CopyFiles: []CopyEntry{{From: ".env", Env: map[string]string{"MY KEY": "1"}}},
+			},
+			wantErr: true,
+		},
+		{
+			name: "linked env overrides",
+			config: &Config{
+				CopyFiles: []CopyEntry{{From: ".env", Mode: CopyModeHardlink, Env: map[string]string{"PORT": "3000"}}},
+			},
+			wantErr: true,
+		},
+		{
+			name: "warm dirs",
+			config: &Config{
+				CopyFiles: []CopyEntry{},
+				WarmDirs:  []WarmDir{{Dir: "node_modules", Lockfiles: []string{"package-lock.json"}, Mode: CopyModeHardlink}},
+			},
+			wantErr: false,
+		},
+		{
+			name: "warm dir without lockfiles",
+			config: &Config{
+				CopyFiles: []CopyEntry{},
+				WarmDirs:  []WarmDir{{Dir: "node_modules"}},
+			},
+			wantErr: true,
+		},
+		{
+			name: "warm dir outside the worktree",
+			config: &Config{
+				CopyFiles: []CopyEntry{},
+				WarmDirs:  []WarmDir{{Dir: "../node_modules", Lockfiles: []string{"package-lock.json"}}},
+			},
+			wantErr: true,
+		},
+		{
+			name: "symlinked warm dir",
+			config: &Config{
+				CopyFiles: []CopyEntry{},
+				WarmDirs:  []WarmDir{{Dir: "node_modules", Lockfiles: []string{"package-lock.json"}, Mode: CopyModeSymlink}},
+			},
+			wantErr: true,
+		},
+		{
+			name: "relative overlay dir",
+			config: &Config{
+				CopyFiles:  []CopyEntry{},
+				OverlayDir: "overlay",
+			},
+			wantErr: true,
+		},
+		{
+			name: "invalid port",
+			config: &Config{
+				CopyFiles: []CopyEntry{},
+				Ports:     map[string]int{"web": 70000},
+			},
+			wantErr: true,
+		},
 		{
 			name: "empty copy_files",
 			config: &Config{
-				CopyFiles: []string{},
+				CopyFiles: []CopyEntry{},
 			},
 			wantErr: false,
 		},
@@ -86,8 +239,42 @@ func TestFindConfigFile(t *testing.T) {
 
 	emptyDir := t.TempDir()
 	_, err = FindConfigFile(emptyDir)
-	if err == nil {
-		t.Error("FindConfigFile() should return error for non-existent config")
+	if !errors.Is(err, ErrConfigNotFound) {
+		t.Errorf("FindConfigFile() error = %v, want ErrConfigNotFound", err)
+	}
+}
+
+func TestLoadOptionalConfig(t *testing.T) {
+	tempDir := t.TempDir()
+	t.Setenv("XDG_CONFIG_HOME", filepath.Join(tempDir, "xdg"))
+	writeLayers(t, map[string]string{
+		filepath.Join(tempDir, "xdg", "sproutee", "config.json"): `{"editor": "cursor", "copy_files": [".env"]}`,
+	})
+
+	repoDir := filepath.Join(tempDir, "repo")
+	if err := os.MkdirAll(repoDir, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	t.Chdir(repoDir)
+
+	config, err := LoadOptionalConfig()
+	if err != nil {
+		t.Fatalf("LoadOptionalConfig() error = %v", err)
+	}
+	if config.Editor != EditorCursor {
+		t.Errorf("Editor = %q, want %q", config.Editor, EditorCursor)
+	}
+	if want := []CopyEntry{{From: ".env"}}; !reflect.DeepEqual(config.CopyFiles, want) {
+		t.Errorf("CopyFiles = %v, want %v", config.CopyFiles, want)
+	}
+
+	t.Setenv("XDG_CONFIG_HOME", filepath.Join(tempDir, "empty"))
+	config, err = LoadOptionalConfig()
+	if err != nil {
+		t.Fatalf("LoadOptionalConfig() without config files error = %v", err)
+	}
+	if !reflect.DeepEqual(config, DefaultConfig()) {
+		t.Errorf("LoadOptionalConfig() without config files = %+v, want the default configuration", config)
 	}
 }
 
@@ -105,7 +292,23 @@ func TestLoadConfig(t *testing.T) {
 			content: `{"copy_files": [".env", "docker-compose.yml"]}`,
 			wantErr: false,
 			validate: func(c *Config) bool {
-				return len(c.CopyFiles) == 2 && c.CopyFiles[0] == ".env"
+				return len(c.CopyFiles) == 2 && c.CopyFiles[0].From == ".env"
+			},
+		},
+		{
+			name:    "clean defaults",
+			content: `{"copy_files": [], "clean": {"delete_branch": true}}`,
+			wantErr: false,
+			validate: func(c *Config) bool {
+				return c.Clean != nil && c.Clean.DeleteBranch && !c.Clean.DeleteRemoteBranch
+			},
+		},
+		{
+			name:    "protected patterns",
+			content: `{"copy_files": [], "protected": ["main", "release/*"]}`,
+			wantErr: false,
+			validate: func(c *Config) bool {
+				return len(c.Protected) == 2 && c.Protected[1] == "release/*"
 			},
 		},
 		{
@@ -145,7 +348,7 @@ func TestSaveConfig(t *testing.T) {
 	configPath := filepath.Join(tempDir, "test_config.json")
 
 	config := &Config{
-		CopyFiles: []string{".env", "docker-compose.yml"},
+		CopyFiles: CopyEntries(".env", "docker-compose.yml"),
 	}
 
 	err := SaveConfig(config, configPath)
@@ -198,3 +401,57 @@ func TestCreateDefaultConfigFile(t *testing.T) {
 		t.Error("CreateDefaultConfigFile() should return error when file already exists")
 	}
 }
+
+func TestCopyEntryJSON(t *testing.T) {
+	var entries []CopyEntry
+	input := `[".env", {"from": "fixtures", "mode": "symlink"}, {"from": "app.env", "env": {"PORT": "{{.Ports.web}}"}}]`
+	if err := json.Unmarshal([]byte(input), &entries); err != nil {
+		t.Fatalf("Unmarshal() error = %v", err)
+	}
+
+	want := []CopyEntry{{From: ".env"}, {From: "fixtures", Mode: CopyModeSymlink}, {From: "app.env", Env: map[string]string{"PORT": "{{.Ports.web}}"}}}
+	if !reflect.DeepEqual(entries, want) {
+		t.Errorf("Unmarshal() = %+v, want %+v", entries, want)
+	}
+
+	data, err := json.Marshal(entries)
+	if err != nil {
+		t.Fatalf("Marshal() error = %v", err)
+	}
+	if got := string(data); got != `[".env",{"from":"fixtures","mode":"symlink"},{"from":"app.env","env":{"PORT":"{{.Ports.web}}"}}]` {
+		t.Errorf("Marshal() = %s", got)
+	}
+}
+
+func TestIsExternalPath(t *testing.T) {
+	tests := map[string]bool{
+		".env":              false,
+		"config/../.env":    false,
+		"../shared/app.env": false,
+		"~/.secrets/a.env":  true,
+		"/etc/app.env":      true,
+	}
+
+	for p, want := range tests {
+		if got := IsExternalPath(p); got != want {
+			t.Errorf("IsExternalPath(%q) = %v, want %v", p, got, want)
+		}
+	}
+}
+
+func TestEscapesRoot(t *testing.T) {
+	tests := map[string]bool{
+		".env":              false,
+		"config/../.env":    false,
+		"..":                true,
+		"../shared/app.env": true,
+		"a/../../b":         true,
+		"..\\windows":       true,
+	}
+
+	for p, want := range tests {
+		if got := EscapesRoot(p); got != want {
+			t.Errorf("EscapesRoot(%q) = %v, want %v", p, got, want)
+		}
+	}
+}
@@ -0,0 +1,213 @@
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// Copy modes for copy_files entries.
+const (
+	CopyModeCopy     = "copy"
+	CopyModeSymlink  = "symlink"
+	CopyModeHardlink = "hardlink"
+	CopyModeClone    = "clone"
+)
+
+// Conflict policies for copy targets that already exist in the worktree.
+const (
+	ConflictOverwrite = "overwrite"
+	ConflictSkip      = "skip"
+	ConflictBackup    = "backup"
+	ConflictFail      = "fail"
+)
+
+// CopyEntry is a copy_files entry. It is written either as a plain string
+// (a path, directory or glob) or as an object with options:
+//
+//	{"from": "config/.env.worktree", "to": ".env", "mode": "copy", "optional": true}
+//
+// To is the target file when From is a single file and the target directory
+// otherwise. Optional entries that select nothing are only noted in the
+// report, while failures of required entries abort worktree creation.
+// Conflict overrides conflict_policy for the entry. Template entries are
+// rendered with text/template for each worktree. Env sets keys in copied
+// .env files; its values are templates as well:
+//
+//	{"from": ".env", "env": {"PORT": "{{.Ports.web}}"}}
+type CopyEntry struct {
+	From     string            `json:"from"`
+	To       string            `json:"to,omitempty"`
+	Mode     string            `json:"mode,omitempty"`
+	Optional bool              `json:"optional,omitempty"`
+	Required bool              `json:"required,omitempty"`
+	Template bool              `json:"template,omitempty"`
+	Conflict string            `json:"conflict,omitempty"`
+	Env      map[string]string `json:"env,omitempty"`
+}
+
+func (e CopyEntry) String() string {
+	s := e.From
+	if e.To != "" {
+		s += " → " + e.To
+	}
+
+	var options []string
+	if e.Mode != "" && e.Mode != CopyModeCopy {
+		options = append(options, e.Mode)
+	}
+	if e.Optional {
+		options = append(options, "optional")
+	}
+	if e.Required {
+		options = append(options, "required")
+	}
+	if e.Template {
+		options = append(options, "template")
+	}
+	if e.Conflict != "" {
+		options = append(options, "on conflict: "+e.Conflict)
+	}
+	if len(e.Env) > 0 {
+		keys := make([]string, 0, len(e.Env))
+		for key := range e.Env {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		options = append(options, "env: "+strings.Join(keys, ", "))
+	}
+	if len(options) > 0 {
+		s += " (" + strings.Join(options, ", ") + ")"
+	}
+	return s
+}
+
+// IsPlain reports whether the entry has no options and can be written as a
+// plain string.
+func (e CopyEntry) IsPlain() bool {
+	return e.To == "" && e.Mode == "" && !e.Optional && !e.Required && !e.Template && e.Conflict == "" && len(e.Env) == 0
+}
+
+func (e *CopyEntry) UnmarshalJSON(data []byte) error {
+	if bytes.HasPrefix(bytes.TrimSpace(data), []byte(`"`)) {
+		*e = CopyEntry{}
+		return json.Unmarshal(data, &e.From)
+	}
+
+	// The alias type drops the methods so decoding does not recurse
+	type entry CopyEntry
+	var decoded entry
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		return err
+	}
+	*e = CopyEntry(decoded)
+	return nil
+}
+
+func (e CopyEntry) MarshalJSON() ([]byte, error) {
+	if e.IsPlain() {
+		return json.Marshal(e.From)
+	}
+
+	type entry CopyEntry
+	return json.Marshal(entry(e))
+}
+
+func validateCopyMode(mode string) error {
+	switch mode {
+	case "", CopyModeCopy, CopyModeSymlink, CopyModeHardlink, CopyModeClone:
+		return nil
+	}
+	return fmt.Errorf("unknown mode '%s' (expected %s, %s, %s or %s)", mode, CopyModeCopy, CopyModeSymlink, CopyModeHardlink, CopyModeClone)
+}
+
+func validateConflictPolicy(policy string) error {
+	switch policy {
+	case "", ConflictOverwrite, ConflictSkip, ConflictBackup, ConflictFail:
+		return nil
+	}
+	return fmt.Errorf("unknown conflict policy '%s' (expected %s, %s, %s or %s)", policy, ConflictOverwrite, ConflictSkip, ConflictBackup, ConflictFail)
+}
+
+// ConflictPolicyFor returns the conflict policy of an entry, falling back to
+// conflict_policy and then to overwriting.
+func (c *Config) ConflictPolicyFor(entry CopyEntry) string {
+	switch {
+	case entry.Conflict != "":
+		return entry.Conflict
+	case c.ConflictPolicy != "":
+		return c.ConflictPolicy
+	default:
+		return ConflictOverwrite
+	}
+}
+
+// IsExternalPath reports whether a path points outside the repository by
+// itself: an absolute path, a path with a volume name or a path starting
+// with "~".
+func IsExternalPath(p string) bool {
+	return p == "~" || strings.HasPrefix(p, "~/") || filepath.IsAbs(p) || strings.HasPrefix(p, "/") || filepath.VolumeName(p) != ""
+}
+
+// EscapesRoot reports whether a relative path leaves the directory it is
+// relative to through "..".
+func EscapesRoot(p string) bool {
+	cleaned := path.Clean(strings.ReplaceAll(p, "\\", "/"))
+	return cleaned == ".." || strings.HasPrefix(cleaned, "../")
+}
+
+// ExpandHome replaces a leading "~" with the user's home directory.
+func ExpandHome(p string) (string, error) {
+	if p != "~" && !strings.HasPrefix(p, "~/") {
+		return p, nil
+	}
+
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", fmt.Errorf("failed to get home directory: %w", err)
+	}
+	return filepath.Join(home, p[1:]), nil
+}
+
+// ExternalSourceAllowed reports whether an absolute source path outside the
+// repository is listed in allowed_external_sources, either exactly, below a
+// listed directory or matching a listed glob.
+func (c *Config) ExternalSourceAllowed(source string) bool {
+	source = filepath.Clean(source)
+	for _, allowed := range c.AllowedExternalSources {
+		expanded, err := ExpandHome(allowed)
+		if err != nil || !filepath.IsAbs(expanded) {
+			continue
+		}
+
+		// Sources may be checked after their symlinks were resolved
+		candidates := []string{filepath.Clean(expanded)}
+		if resolved, err := filepath.EvalSymlinks(expanded); err == nil && resolved != candidates[0] {
+			candidates = append(candidates, resolved)
+		}
+
+		for _, candidate := range candidates {
+			if source == candidate || strings.HasPrefix(source, candidate+string(filepath.Separator)) {
+				return true
+			}
+			if ok, err := filepath.Match(candidate, source); err == nil && ok {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// CopyEntries builds plain copy_files entries from paths.
+func CopyEntries(paths ...string) []CopyEntry {
+	entries := make([]CopyEntry, len(paths))
+	for i, p := range paths {
+		entries[i] = CopyEntry{From: p}
+	}
+	return entries
+}
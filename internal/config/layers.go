@@ -0,0 +1,310 @@
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const (
+	// LocalConfigFileName is the untracked file next to sproutee.json that
+	// holds personal overrides.
+	LocalConfigFileName = "sproutee.local.json"
+
+	// ReplaceKey lists the keys, such as "copy_files" or
+	// "copy_ignored.include", whose lists replace those of earlier layers
+	// instead of being appended to. ReplaceEnv is its environment form.
+	ReplaceKey = "replace"
+	ReplaceEnv = "SPROUTEE_REPLACE"
+)
+
+// personalKeys may only be set in the global config and an untracked
+// sproutee.local.json, so that a cloned repository cannot grant itself access
+// to the user's files.
+var personalKeys = []string{"allowed_external_sources", "overlay_dir"}
+
+// Origins maps the keys of a merged configuration to the file or environment
+// variable that set them. Nested keys are joined with dots and list items are
+// indexed, e.g. "clean.delete_branch" and "copy_files[2]".
+type Origins map[string]string
+
+// envKeys maps the environment variables of the last layer to configuration
+// keys. Lists are comma-separated.
+var envKeys = map[string]string{
+	"SPROUTEE_COPY_FILES":      "copy_files",
+	"SPROUTEE_EXCLUDE_FILES":   "exclude_files",
+	"SPROUTEE_PROTECTED":       "protected",
+	"SPROUTEE_CONFLICT_POLICY": "conflict_policy",
+	"SPROUTEE_COPY_WORKERS":    "copy_workers",
+	"SPROUTEE_VERIFY_COPIES":   "verify_copies",
+	"SPROUTEE_COPY_FROM":       "copy_from",
+	"SPROUTEE_OVERLAY_DIR":     "overlay_dir",
+	"SPROUTEE_EDITOR":          "editor",
+}
+
+// GlobalConfigPath returns the user's configuration file,
+// $XDG_CONFIG_HOME/sproutee/config.json or ~/.config/sproutee/config.json.
+func GlobalConfigPath() (string, error) {
+	if dir := os.Getenv("XDG_CONFIG_HOME"); dir != "" {
+		return filepath.Join(dir, "sproutee", "config.json"), nil
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", fmt.Errorf("failed to get home directory: %w", err)
+	}
+	return filepath.Join(home, ".config", "sproutee", "config.json"), nil
+}
+
+// LoadLayeredConfig loads the repository configuration at configPath on top
+// of the global configuration, then applies sproutee.local.json from the
+// same directory and SPROUTEE_* environment variables. Only the repository
+// file is required, and neither it nor a sproutee.local.json committed to the
+// repository may set personal keys such as allowed_external_sources.
+//
+// Later layers replace values and merge objects key by key. Lists are
+// appended to, skipping items an earlier layer already has, unless the
+// layer names them in "replace".
+func LoadLayeredConfig(configPath string) (*Config, Origins, error) {
+	return loadLayers(configPath, true)
+}
+
+// loadLayers is LoadLayeredConfig for a repository file that may be missing,
+// in which case the other layers apply to the default configuration.
+func loadLayers(configPath string, required bool) (*Config, Origins, error) {
+	merged := map[string]any{}
+	origins := Origins{}
+
+	globalPath, err := GlobalConfigPath()
+	if err != nil {
+		return nil, nil, err
+	}
+	localPath := filepath.Join(filepath.Dir(configPath), LocalConfigFileName)
+	files := []struct {
+		path     string
+		required bool
+		personal bool
+	}{
+		{globalPath, false, true},
+		{configPath, required, false},
+		{localPath, false, true},
+	}
+	for _, file := range files {
+		layer, err := readLayer(file.path)
+		if errors.Is(err, fs.ErrNotExist) && !file.required {
+			continue
+		}
+		if err != nil {
+			return nil, nil, err
+		}
+		if !file.personal || (file.path == localPath && isTracked(file.path)) {
+			for _, key := range personalKeys {
+				if _, ok := layer[key]; ok {
+					return nil, nil, fmt.Errorf("%s can only be set in %s or an untracked %s, not in %s", key, globalPath, LocalConfigFileName, file.path)
+				}
+			}
+		}
+		replace, err := replaceKeys(layer[ReplaceKey])
+		if err != nil {
+			return nil, nil, fmt.Errorf("invalid '%s' in %s: %w", ReplaceKey, file.path, err)
+		}
+		delete(layer, ReplaceKey)
+		mergeObject(merged, layer, "", replace, file.path, origins)
+	}
+
+	if _, ok := merged["copy_files"]; !ok && !required {
+		merged["copy_files"] = []any{}
+	}
+	if err := mergeEnv(merged, origins); err != nil {
+		return nil, nil, err
+	}
+
+	data, err := json.Marshal(merged)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to merge config files: %w", err)
+	}
+	var config Config
+	if err := json.Unmarshal(data, &config); err != nil {
+		return nil, nil, fmt.Errorf("failed to parse config file: %w", err)
+	}
+	if err := config.Validate(); err != nil {
+		return nil, nil, fmt.Errorf("invalid configuration: %w", err)
+	}
+	return &config, origins, nil
+}
+
+// isTracked reports whether git tracks the file, i.e. it was committed to or
+// staged in the repository. Files outside a repository are not tracked.
+func isTracked(file string) bool {
+	cmd := exec.Command("git", "ls-files", "--error-unmatch", "--", filepath.Base(file))
+	cmd.Dir = filepath.Dir(file)
+	return cmd.Run() == nil
+}
+
+func readLayer(layerPath string) (map[string]any, error) {
+	data, err := os.ReadFile(layerPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read config file: %w", err)
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	var layer map[string]any
+	if err := decoder.Decode(&layer); err != nil {
+		return nil, fmt.Errorf("failed to parse config file %s: %w", layerPath, err)
+	}
+	if layer == nil {
+		return nil, fmt.Errorf("failed to parse config file %s: expected an object", layerPath)
+	}
+	return layer, nil
+}
+
+func replaceKeys(value any) (map[string]bool, error) {
+	replace := map[string]bool{}
+	if value == nil {
+		return replace, nil
+	}
+	keys, ok := value.([]any)
+	if !ok {
+		return nil, fmt.Errorf("expected a list of keys")
+	}
+	for _, key := range keys {
+		name, ok := key.(string)
+		if !ok {
+			return nil, fmt.Errorf("expected a list of keys")
+		}
+		replace[name] = true
+	}
+	return replace, nil
+}
+
+// mergeEnv applies the SPROUTEE_* variables as the last layer.
+func mergeEnv(merged map[string]any, origins Origins) error {
+	replace := map[string]bool{}
+	if value := os.Getenv(ReplaceEnv); value != "" {
+		for _, key := range splitList(value) {
+			replace[key.(string)] = true
+		}
+	}
+
+	for _, name := range sortedKeys(envKeys) {
+		value, ok := os.LookupEnv(name)
+		if !ok {
+			continue
+		}
+
+		key := envKeys[name]
+		var parsed any = value
+		switch key {
+		case "copy_files", "exclude_files", "protected":
+			parsed = splitList(value)
+		case "copy_workers":
+			n, err := strconv.Atoi(value)
+			if err != nil {
+				return fmt.Errorf("invalid %s: %w", name, err)
+			}
+			parsed = json.Number(strconv.Itoa(n))
+		case "verify_copies":
+			b, err := strconv.ParseBool(value)
+			if err != nil {
+				return fmt.Errorf("invalid %s: %w", name, err)
+			}
+			parsed = b
+		}
+		mergeObject(merged, map[string]any{key: parsed}, "", replace, "$"+name, origins)
+	}
+	return nil
+}
+
+func splitList(value string) []any {
+	var items []any
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+func mergeObject(dst, src map[string]any, prefix string, replace map[string]bool, source string, origins Origins) {
+	for _, key := range sortedKeys(src) {
+		keyPath := key
+		if prefix != "" {
+			keyPath = prefix + "." + key
+		}
+		value := src[key]
+
+		switch value := value.(type) {
+		case map[string]any:
+			if existing, ok := dst[key].(map[string]any); ok {
+				mergeObject(existing, value, keyPath, replace, source, origins)
+				continue
+			}
+		case []any:
+			if existing, ok := dst[key].([]any); ok && !replace[keyPath] {
+				for _, item := range value {
+					if containsValue(existing, item) {
+						continue
+					}
+					setOrigins(origins, fmt.Sprintf("%s[%d]", keyPath, len(existing)), item, source)
+					existing = append(existing, item)
+				}
+				dst[key] = existing
+				origins[keyPath] = source
+				continue
+			}
+		}
+
+		clearOrigins(origins, keyPath)
+		setOrigins(origins, keyPath, value, source)
+		dst[key] = value
+	}
+}
+
+func setOrigins(origins Origins, keyPath string, value any, source string) {
+	origins[keyPath] = source
+	switch value := value.(type) {
+	case map[string]any:
+		for key, child := range value {
+			setOrigins(origins, keyPath+"."+key, child, source)
+		}
+	case []any:
+		for i, item := range value {
+			setOrigins(origins, fmt.Sprintf("%s[%d]", keyPath, i), item, source)
+		}
+	}
+}
+
+func clearOrigins(origins Origins, keyPath string) {
+	for key := range origins {
+		if key == keyPath || strings.HasPrefix(key, keyPath+".") || strings.HasPrefix(key, keyPath+"[") {
+			delete(origins, key)
+		}
+	}
+}
+
+func containsValue(items []any, value any) bool {
+	for _, item := range items {
+		if reflect.DeepEqual(item, value) {
+			return true
+		}
+	}
+	return false
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,129 @@
+package config
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func writeLayers(t *testing.T, files map[string]string) {
+	t.Helper()
+	for name, content := range files {
+		if err := os.MkdirAll(filepath.Dir(name), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(name, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestLoadLayeredConfig(t *testing.T) {
+	tempDir := t.TempDir()
+	t.Setenv("XDG_CONFIG_HOME", filepath.Join(tempDir, "xdg"))
+	t.Setenv("SPROUTEE_COPY_FROM", "main")
+	t.Setenv("SPROUTEE_VERIFY_COPIES", "true")
+
+	globalPath := filepath.Join(tempDir, "xdg", "sproutee", "config.json")
+	repoPath := filepath.Join(tempDir, "repo", ConfigFileName)
+	localPath := filepath.Join(tempDir, "repo", LocalConfigFileName)
+	writeLayers(t, map[string]string{
+		globalPath: `{"editor": "cursor", "copy_files": [".idea/"], "init_scripts": ["direnv allow"], "clean": {"delete_branch": true}}`,
+		repoPath:   `{"copy_files": [".env", ".idea/"], "init_scripts": ["npm ci"], "ports": {"web": 3000}, "clean": {"delete_remote_branch": true}}`,
+		localPath:  `{"editor": "vscode", "copy_files": [".env.local"], "allowed_external_sources": ["~/.secrets"], "init_scripts": ["make setup"], "ports": {"db": 5432}, "replace": ["init_scripts"]}`,
+	})
+
+	cfg, origins, err := LoadLayeredConfig(repoPath)
+	if err != nil {
+		t.Fatalf("LoadLayeredConfig() error = %v", err)
+	}
+
+	want := &Config{
+		CopyFiles:              CopyEntries(".idea/", ".env", ".env.local"),
+		AllowedExternalSources: []string{"~/.secrets"},
+		InitScripts:            []string{"make setup"},
+		Ports:                  map[string]int{"web": 3000, "db": 5432},
+		Clean:                  &CleanConfig{DeleteBranch: true, DeleteRemoteBranch: true},
+		Editor:                 EditorVSCode,
+		CopyFrom:               "main",
+		VerifyCopies:           true,
+	}
+	if !reflect.DeepEqual(cfg, want) {
+		t.Errorf("LoadLayeredConfig() = %+v, want %+v", cfg, want)
+	}
+
+	wantOrigins := map[string]string{
+		"copy_files[0]":              globalPath,
+		"copy_files[1]":              repoPath,
+		"copy_files[2]":              localPath,
+		"init_scripts[0]":            localPath,
+		"ports.web":                  repoPath,
+		"ports.db":                   localPath,
+		"clean.delete_branch":        globalPath,
+		"clean.delete_remote_branch": repoPath,
+		"editor":                     localPath,
+		"copy_from":                  "$SPROUTEE_COPY_FROM",
+		"verify_copies":              "$SPROUTEE_VERIFY_COPIES",
+	}
+	for key, source := range wantOrigins {
+		if origins[key] != source {
+			t.Errorf("origin of %s = %q, want %q", key, origins[key], source)
+		}
+	}
+	if _, ok := origins["init_scripts[1]"]; ok {
+		t.Error("replaced list items should not keep their origins")
+	}
+}
+
+func TestLoadLayeredConfigErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		repo    string
+		local   string
+		tracked bool
+		env     map[string]string
+	}{
+		{name: "external sources in the repository", repo: `{"copy_files": [], "allowed_external_sources": ["~/.ssh"]}`},
+		{name: "overlay directory in the repository", repo: `{"copy_files": [], "overlay_dir": "~/.ssh"}`},
+		{name: "external sources in a tracked local file", local: `{"allowed_external_sources": ["~/.ssh"]}`, tracked: true},
+		{name: "invalid local file", local: `{"copy_files": [`},
+		{name: "invalid merged config", local: `{"editor": "vim"}`},
+		{name: "invalid replace", local: `{"replace": "copy_files"}`},
+		{name: "invalid env value", env: map[string]string{"SPROUTEE_COPY_WORKERS": "many"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tempDir := t.TempDir()
+			t.Setenv("XDG_CONFIG_HOME", filepath.Join(tempDir, "xdg"))
+			for name, value := range tt.env {
+				t.Setenv(name, value)
+			}
+
+			repoPath := filepath.Join(tempDir, ConfigFileName)
+			files := map[string]string{repoPath: `{"copy_files": []}`}
+			if tt.repo != "" {
+				files[repoPath] = tt.repo
+			}
+			if tt.local != "" {
+				files[filepath.Join(tempDir, LocalConfigFileName)] = tt.local
+			}
+			writeLayers(t, files)
+			if tt.tracked {
+				for _, args := range [][]string{{"init", "-q"}, {"add", LocalConfigFileName}} {
+					cmd := exec.Command("git", args...)
+					cmd.Dir = tempDir
+					if output, err := cmd.CombinedOutput(); err != nil {
+						t.Fatalf("git %v failed: %v\n%s", args, err, output)
+					}
+				}
+			}
+
+			if _, _, err := LoadLayeredConfig(repoPath); err == nil {
+				t.Error("LoadLayeredConfig() should return an error")
+			}
+		})
+	}
+}
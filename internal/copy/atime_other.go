@@ -0,0 +1,14 @@
+//go:build !linux && !darwin
+
+package copy
+
+import (
+	"os"
+	"time"
+)
+
+// accessTime returns the modification time, as the access time is not read
+// on this platform.
+func accessTime(info os.FileInfo) time.Time {
+	return info.ModTime()
+}
@@ -0,0 +1,48 @@
+//go:build linux
+
+package copy
+
+import (
+	"errors"
+	"fmt"
+	"os"
+
+	"golang.org/x/sys/unix"
+)
+
+// cloneFile creates dst as a copy-on-write clone of src with the FICLONE
+// ioctl.
+func cloneFile(src, dst string) error {
+	sourceFile, err := os.Open(src)
+	if err != nil {
+		return fmt.Errorf("failed to open source file: %w", err)
+	}
+	defer sourceFile.Close()
+
+	sourceInfo, err := sourceFile.Stat()
+	if err != nil {
+		return fmt.Errorf("failed to stat source file: %w", err)
+	}
+
+	targetFile, err := os.OpenFile(dst, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, sourceInfo.Mode().Perm())
+	if err != nil {
+		return fmt.Errorf("failed to create target file: %w", err)
+	}
+
+	err = unix.IoctlFileClone(int(targetFile.Fd()), int(sourceFile.Fd()))
+	closeErr := targetFile.Close()
+	if err != nil {
+		_ = os.Remove(dst)
+		if errors.Is(err, unix.EOPNOTSUPP) || errors.Is(err, unix.ENOTTY) || errors.Is(err, unix.EXDEV) ||
+			errors.Is(err, unix.EINVAL) || errors.Is(err, unix.ENOSYS) || errors.Is(err, unix.EPERM) {
+			return errCloneUnsupported
+		}
+		return fmt.Errorf("failed to clone file: %w", err)
+	}
+	if closeErr != nil {
+		return fmt.Errorf("failed to close target file: %w", closeErr)
+	}
+
+	_ = os.Chmod(dst, sourceInfo.Mode())
+	return nil
+}
@@ -0,0 +1,7 @@
+//go:build !linux
+
+package copy
+
+func cloneFile(_, _ string) error {
+	return errCloneUnsupported
+}
@@ -0,0 +1,65 @@
+package copy
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+
+	"github.com/daisuke310vvv/sproutee/internal/config"
+)
+
+// prepareTarget applies the conflict policy when the target of a result
+// already exists. It reports whether the target existed; results whose target
+// is kept are marked as skipped.
+func prepareTarget(result *Result, policy string) (bool, error) {
+	if _, err := os.Lstat(result.TargetPath); err != nil {
+		if os.IsNotExist(err) {
+			return false, nil
+		}
+		return false, fmt.Errorf("failed to inspect target file: %w", err)
+	}
+
+	switch policy {
+	case config.ConflictSkip:
+		result.Skipped = true
+		result.SkipReason = SkipReasonTargetExists
+	case config.ConflictFail:
+		return true, fmt.Errorf("target file already exists: %s", result.TargetPath)
+	case config.ConflictBackup:
+		backupPath, err := backupFile(result.TargetPath)
+		if err != nil {
+			return true, err
+		}
+		result.BackupPath = backupPath
+	}
+	return true, nil
+}
+
+// backupFile keeps a copy of path as path.bak (or path.bak.N when that is
+// taken). The backup is a hard link when possible so the original stays in
+// place until the new file is renamed over it.
+func backupFile(path string) (string, error) {
+	backupPath := path + ".bak"
+	for i := 1; ; i++ {
+		if _, err := os.Lstat(backupPath); os.IsNotExist(err) {
+			break
+		}
+		backupPath = fmt.Sprintf("%s.bak.%d", path, i)
+	}
+
+	if err := os.Link(path, backupPath); err == nil {
+		return backupPath, nil
+	}
+	if err := File(path, backupPath); err != nil {
+		return "", fmt.Errorf("failed to back up target file: %w", err)
+	}
+	return backupPath, nil
+}
+
+// isTracked reports whether the slash-separated path is tracked by Git in
+// the worktree.
+func isTracked(worktreePath, relativePath string) bool {
+	cmd := exec.Command("git", "ls-files", "--error-unmatch", "--", relativePath) // #nosec G204
+	cmd.Dir = worktreePath
+	return cmd.Run() == nil
+}
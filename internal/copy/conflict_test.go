@@ -0,0 +1,127 @@
+package copy
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+
+	"github.com/daisuke310vvv/sproutee/internal/config"
+)
+
+func TestFilesFromConfigConflictPolicies(t *testing.T) {
+	tests := []struct {
+		policy      string
+		wantContent string
+		wantBackup  bool
+		wantSkipped bool
+		wantFailure bool
+	}{
+		{config.ConflictOverwrite, "new", false, false, false},
+		{config.ConflictSkip, "old", false, true, false},
+		{config.ConflictBackup, "new", true, false, false},
+		{config.ConflictFail, "old", false, false, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.policy, func(t *testing.T) {
+			tempDir := t.TempDir()
+			srcRoot := filepath.Join(tempDir, "src")
+			targetRoot := filepath.Join(tempDir, "target")
+			writeTree(t, srcRoot, map[string]string{".env": "new"})
+			writeTree(t, targetRoot, map[string]string{".env": "old"})
+
+			cfg := &config.Config{
+				CopyFiles:      []config.CopyEntry{{From: ".env"}},
+				ConflictPolicy: tt.policy,
+			}
+			report := FilesFromConfig(srcRoot, targetRoot, cfg)
+			result := report.Results[0]
+
+			if (report.FailureCount == 1) != tt.wantFailure {
+				t.Errorf("FailureCount = %d, wantFailure %v", report.FailureCount, tt.wantFailure)
+			}
+			if result.Skipped != tt.wantSkipped || (report.SkippedCount == 1) != tt.wantSkipped {
+				t.Errorf("Skipped = %v, SkippedCount = %d, want %v", result.Skipped, report.SkippedCount, tt.wantSkipped)
+			}
+
+			content, err := os.ReadFile(filepath.Join(targetRoot, ".env"))
+			if err != nil || string(content) != tt.wantContent {
+				t.Errorf("target content = %q, %v, want %q", content, err, tt.wantContent)
+			}
+
+			if tt.wantBackup {
+				backup, err := os.ReadFile(result.BackupPath)
+				if err != nil || string(backup) != "old" {
+					t.Errorf("backup content = %q, %v, want %q", backup, err, "old")
+				}
+			} else if result.BackupPath != "" {
+				t.Errorf("BackupPath = %s, want none", result.BackupPath)
+			}
+		})
+	}
+}
+
+func TestFilesFromConfigEntryConflictOverridesDefault(t *testing.T) {
+	tempDir := t.TempDir()
+	srcRoot := filepath.Join(tempDir, "src")
+	targetRoot := filepath.Join(tempDir, "target")
+	writeTree(t, srcRoot, map[string]string{".env": "new", "Makefile": "new"})
+	writeTree(t, targetRoot, map[string]string{".env": "old", "Makefile": "old"})
+
+	cfg := &config.Config{
+		CopyFiles:      []config.CopyEntry{{From: ".env", Conflict: config.ConflictOverwrite}, {From: "Makefile"}},
+		ConflictPolicy: config.ConflictSkip,
+	}
+	report := FilesFromConfig(srcRoot, targetRoot, cfg)
+	if report.SuccessCount != 1 || report.SkippedCount != 1 {
+		t.Errorf("FilesFromConfig() = %d successes, %d skipped, want 1 and 1", report.SuccessCount, report.SkippedCount)
+	}
+}
+
+func TestFilesFromConfigOverwroteTracked(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git is not available")
+	}
+
+	tempDir := t.TempDir()
+	srcRoot := filepath.Join(tempDir, "src")
+	targetRoot := filepath.Join(tempDir, "target")
+	writeTree(t, srcRoot, map[string]string{"Makefile": "local", "notes.txt": "local"})
+	writeTree(t, targetRoot, map[string]string{"Makefile": "tracked", "notes.txt": "untracked"})
+
+	for _, args := range [][]string{{"init", "-q"}, {"add", "Makefile"}} {
+		cmd := exec.Command("git", args...)
+		cmd.Dir = targetRoot
+		if output, err := cmd.CombinedOutput(); err != nil {
+			t.Fatalf("git %v failed: %v\n%s", args, err, output)
+		}
+	}
+
+	report := FilesFromConfig(srcRoot, targetRoot, &config.Config{CopyFiles: config.CopyEntries("Makefile", "notes.txt")})
+	for _, result := range report.Results {
+		want := filepath.Base(result.TargetPath) == "Makefile"
+		if result.OverwroteTracked != want {
+			t.Errorf("OverwroteTracked for %s = %v, want %v", result.TargetPath, result.OverwroteTracked, want)
+		}
+	}
+}
+
+func TestFileIsAtomic(t *testing.T) {
+	tempDir := t.TempDir()
+	src := filepath.Join(tempDir, "src.txt")
+	dst := filepath.Join(tempDir, "out", "dst.txt")
+	writeTree(t, tempDir, map[string]string{"src.txt": "content", "out/dst.txt": "old"})
+
+	if err := File(src, dst); err != nil {
+		t.Fatalf("File() error = %v", err)
+	}
+
+	entries, err := os.ReadDir(filepath.Dir(dst))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 || entries[0].Name() != "dst.txt" {
+		t.Errorf("target directory should only contain dst.txt, got %v", entries)
+	}
+}
@@ -4,34 +4,85 @@ import (
 	"fmt"
 	"io"
 	"os"
+	"path"
 	"path/filepath"
+	"runtime"
 	"strings"
+	"sync"
+	"time"
 
 	"github.com/daisuke310vvv/sproutee/internal/config"
+	"github.com/daisuke310vvv/sproutee/internal/vault"
+)
+
+// Origins tell which part of the configuration selected a file.
+const (
+	OriginCopyFiles   = "copy_files"
+	OriginCopyIgnored = "copy_ignored"
+	OriginOverlay     = "overlay"
+	OriginVault       = "vault"
 )
 
 type Result struct {
 	SourcePath string
 	TargetPath string
-	Success    bool
-	Error      error
-}
+	Pattern    string
+
+	// RelativePath is the slash-separated target path in the worktree.
+	RelativePath string
+	Origin       string
+	Mode         string
+	Success      bool
+	Error        error
+
+	// Status tells whether a file that was not skipped was copied, found
+	// unchanged or copied and verified.
+	Status string
+
+	// Required is set for results of entries marked as required, whose
+	// failure makes the copy unusable.
+	Required bool
+	Template bool
+
+	// EnvKeys lists the dotenv keys that were overridden in the copy.
+	EnvKeys []string
+
+	// Skipped is set when nothing was copied on purpose; SkipReason says why.
+	Skipped          bool
+	SkipReason       string
+	BackupPath       string
+	OverwroteTracked bool
 
-type Report struct {
-	Results      []Result
-	TotalFiles   int
-	SuccessCount int
-	FailureCount int
+	// Bytes is the size of the file written, or of the file left unchanged;
+	// links write no bytes. Duration is the time the file took.
+	Bytes    int64
+	Duration time.Duration
 }
 
-func (r *Report) AddResult(result Result) {
-	r.Results = append(r.Results, result)
-	r.TotalFiles++
-	if result.Success {
-		r.SuccessCount++
-	} else {
-		r.FailureCount++
-	}
+// Reasons for skipped results.
+const (
+	SkipReasonTargetExists    = "target exists"
+	SkipReasonOptionalMissing = "optional source missing"
+)
+
+// plannedCopy is a file selected by a copy_files entry, or the entry itself
+// when it selected nothing. sourceRel is the slash-separated source path
+// relative to the repository and is empty for sources outside it;
+// relativePath is the slash-separated target path in the worktree.
+type plannedCopy struct {
+	pattern      string
+	origin       string
+	mode         string
+	conflict     string
+	required     bool
+	optional     bool
+	template     bool
+	env          map[string]string
+	secret       *vault.Vault
+	sourcePath   string
+	sourceRel    string
+	relativePath string
+	err          error
 }
 
 func FileExists(filePath string) bool {
@@ -39,7 +90,14 @@ func FileExists(filePath string) bool {
 	return err == nil
 }
 
+// File copies src to dst. The content is written to a temporary file next to
+// dst that is renamed over it, so dst is never left partially written.
 func File(src, dst string) error {
+	// Leave identical files alone rather than rewriting them
+	if sameContent(src, dst) {
+		return nil
+	}
+
 	sourceFile, err := os.Open(src)
 	if err != nil {
 		return fmt.Errorf("failed to open source file: %w", err)
@@ -50,23 +108,28 @@ func File(src, dst string) error {
 		return fmt.Errorf("failed to create target directory: %w", err)
 	}
 
-	targetFile, err := os.Create(dst)
+	targetFile, err := os.CreateTemp(filepath.Dir(dst), tempPattern(dst))
 	if err != nil {
 		return fmt.Errorf("failed to create target file: %w", err)
 	}
-	defer targetFile.Close()
+	tempPath := targetFile.Name()
 
-	_, err = io.Copy(targetFile, sourceFile)
-	if err != nil {
+	if _, err := io.Copy(targetFile, sourceFile); err != nil {
+		_ = targetFile.Close()
+		_ = os.Remove(tempPath)
 		return fmt.Errorf("failed to copy file content: %w", err)
 	}
 
-	sourceInfo, err := sourceFile.Stat()
-	if err == nil {
+	if sourceInfo, err := sourceFile.Stat(); err == nil {
 		_ = targetFile.Chmod(sourceInfo.Mode())
 	}
 
-	return nil
+	if err := targetFile.Close(); err != nil {
+		_ = os.Remove(tempPath)
+		return fmt.Errorf("failed to write target file: %w", err)
+	}
+
+	return replaceFile(tempPath, dst)
 }
 
 func FileWithStructure(srcRoot, targetRoot, relativePath string) error {
@@ -80,71 +143,427 @@ func FileWithStructure(srcRoot, targetRoot, relativePath string) error {
 	return File(srcPath, dstPath)
 }
 
-func FilesFromConfig(srcRoot, targetRoot string, cfg *config.Config) *Report {
-	report := &Report{}
+// resolveEntry returns the slash-separated relative paths of the files a
+// copy_files entry selects: a single file, every file below a directory, or
+// every file matching a glob. Globs only walk the directories they can match.
+func resolveEntry(tree *fileTree, entry string) ([]string, error) {
+	srcRoot := tree.root
+	pattern := path.Clean(filepath.ToSlash(entry))
 
-	for _, filePath := range cfg.CopyFiles {
-		result := Result{
-			SourcePath: filepath.Join(srcRoot, filePath),
-			TargetPath: filepath.Join(targetRoot, filePath),
+	if !hasMeta(pattern) {
+		srcPath := filepath.Join(srcRoot, filepath.FromSlash(pattern))
+		info, err := os.Stat(srcPath)
+		if err != nil {
+			return nil, fmt.Errorf("source file does not exist: %s", srcPath)
+		}
+		if !info.IsDir() {
+			return []string{pattern}, nil
 		}
 
-		if !FileExists(result.SourcePath) {
-			result.Success = false
-			result.Error = fmt.Errorf("source file does not exist: %s", result.SourcePath)
-		} else {
-			err := FileWithStructure(srcRoot, targetRoot, filePath)
-			if err != nil {
-				result.Success = false
-				result.Error = err
-			} else {
-				result.Success = true
-			}
+		files, err := tree.walk(pattern, nil)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read directory: %w", err)
 		}
+		if len(files) == 0 {
+			return nil, fmt.Errorf("source directory is empty: %s", srcPath)
+		}
+		return files, nil
+	}
 
-		report.AddResult(result)
+	var matches []string
+	base := staticPrefix(pattern)
+	if FileExists(filepath.Join(srcRoot, filepath.FromSlash(base))) {
+		files, err := tree.walk(base, func(dir string) bool {
+			return canMatchBelow(pattern, dir)
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to search for pattern: %w", err)
+		}
+		for _, file := range files {
+			if MatchPattern(pattern, file) {
+				matches = append(matches, file)
+			}
+		}
 	}
 
-	return report
+	if len(matches) == 0 {
+		return nil, fmt.Errorf("no files match pattern: %s", entry)
+	}
+	return matches, nil
 }
 
-func FilesToWorktree(sourceRepoRoot, worktreePath string) (*Report, error) {
-	cfg, err := config.LoadConfigFromCurrentDir()
+// entrySource splits a copy_files source into the directory it is resolved
+// from and the slash-separated pattern relative to that directory. Relative
+// sources may not leave the repository; absolute sources must be listed in
+// allowed_external_sources.
+func entrySource(srcRoot string, cfg *config.Config, from string) (root, pattern string, external bool, err error) {
+	if config.EscapesRoot(from) {
+		return "", "", false, fmt.Errorf("source leaves the repository: %s", from)
+	}
+	if !config.IsExternalPath(from) {
+		return srcRoot, path.Clean(filepath.ToSlash(from)), false, nil
+	}
+
+	source, err := config.ExpandHome(from)
 	if err != nil {
-		return nil, fmt.Errorf("failed to load configuration: %w", err)
+		return "", "", true, err
+	}
+	if !filepath.IsAbs(source) {
+		return "", "", true, fmt.Errorf("source is not an absolute path: %s", from)
+	}
+	source = filepath.Clean(source)
+
+	if !cfg.ExternalSourceAllowed(source) {
+		return "", "", true, fmt.Errorf("source outside the repository is not listed in allowed_external_sources: %s", from)
 	}
 
-	return FilesFromConfig(sourceRepoRoot, worktreePath, cfg), nil
+	slashed := filepath.ToSlash(source)
+	if !hasMeta(slashed) {
+		return filepath.Dir(source), filepath.Base(source), true, nil
+	}
+
+	prefix := staticPrefix(slashed)
+	if prefix == "" {
+		return "/", strings.TrimPrefix(slashed, "/"), true, nil
+	}
+	return filepath.FromSlash(prefix), strings.TrimPrefix(slashed, prefix+"/"), true, nil
 }
 
-func (r *Report) PrintSummary() {
-	if r.TotalFiles == 0 {
-		fmt.Println("📁 No files configured for copying.")
-		return
+// targetPath maps a file selected by an entry to its slash-separated path in
+// the worktree. Without "to" files keep their path; with it a single file is
+// written to "to" (or into it when it ends with "/"), and the files of a
+// directory or glob are placed below "to" relative to the entry's base.
+func targetPath(entry config.CopyEntry, pattern string, single bool, file string) string {
+	if entry.To == "" {
+		return file
+	}
+
+	to := path.Clean(filepath.ToSlash(entry.To))
+	if single {
+		if strings.HasSuffix(entry.To, "/") {
+			return path.Join(to, path.Base(file))
+		}
+		return to
+	}
+
+	base := pattern
+	if hasMeta(pattern) {
+		base = staticPrefix(pattern)
 	}
+	if base == "" {
+		return path.Join(to, file)
+	}
+	return path.Join(to, strings.TrimPrefix(file, base+"/"))
+}
 
-	fmt.Printf("📁 File Copy Summary:\n")
-	fmt.Printf("   Total files: %d\n", r.TotalFiles)
-	fmt.Printf("   ✅ Successful: %d\n", r.SuccessCount)
+// planCopies expands the copy_files entries in order. Entries starting with
+// "!" drop files selected by earlier entries. Git-ignored files are added
+// after them when copy_ignored is enabled, and exclude_files drops files
+// selected by any entry.
+func planCopies(srcRoot string, cfg *config.Config) []plannedCopy {
+	var planned []plannedCopy
+	seen := make(map[string]bool)
+	trees := make(map[string]*fileTree)
+
+	for _, entry := range cfg.CopyFiles {
+		if negated, ok := strings.CutPrefix(entry.From, "!"); ok {
+			planned = dropMatching(planned, negated)
+			continue
+		}
+
+		base := plannedCopy{
+			pattern:  entry.From,
+			origin:   OriginCopyFiles,
+			mode:     entry.Mode,
+			conflict: cfg.ConflictPolicyFor(entry),
+			required: entry.Required,
+			optional: entry.Optional,
+			template: entry.Template,
+			env:      entry.Env,
+		}
+
+		var files []string
+		root, pattern, external, err := entrySource(srcRoot, cfg, entry.From)
+		if err == nil && (entry.To != "" && (config.IsExternalPath(entry.To) || config.EscapesRoot(entry.To))) {
+			root, err = "", fmt.Errorf("target leaves the worktree: %s", entry.To)
+		}
+		if err == nil {
+			if trees[root] == nil {
+				trees[root] = newFileTree(root)
+			}
+			files, err = resolveEntry(trees[root], pattern)
+		} else {
+			// A source that is not allowed is an error even for optional entries
+			base.optional = false
+		}
+		if err != nil {
+			failed := base
+			failed.sourcePath, failed.relativePath, failed.err = entry.From, filepath.ToSlash(entry.From), err
+			if root != "" {
+				failed.sourcePath = filepath.Join(root, filepath.FromSlash(pattern))
+				failed.relativePath = targetPath(entry, pattern, true, pattern)
+			}
+			planned = append(planned, failed)
+			continue
+		}
+
+		single := !hasMeta(pattern) && len(files) == 1 && files[0] == pattern
+		for _, file := range files {
+			target := targetPath(entry, pattern, single, file)
+			if seen[target] {
+				continue
+			}
+			seen[target] = true
+
+			p := base
+			p.sourcePath, p.relativePath = filepath.Join(root, filepath.FromSlash(file)), target
+			if !external {
+				p.sourceRel = file
+			}
+			if err := checkSource(srcRoot, cfg, p.sourcePath); err != nil {
+				p.err, p.optional = err, false
+			}
+			planned = append(planned, p)
+		}
+	}
 
-	if r.FailureCount > 0 {
-		fmt.Printf("   ❌ Failed: %d\n", r.FailureCount)
-		fmt.Println("\n📋 Failed copies:")
-		for _, result := range r.Results {
-			if !result.Success {
-				fmt.Printf("   • %s → %s\n", result.SourcePath, result.TargetPath)
-				fmt.Printf("     Error: %v\n", result.Error)
+	if cfg.CopyIgnored != nil && cfg.CopyIgnored.Enabled {
+		files, err := FindIgnoredFiles(srcRoot, cfg.CopyIgnored)
+		if err != nil {
+			planned = append(planned, plannedCopy{origin: OriginCopyIgnored, sourcePath: srcRoot, relativePath: ".", err: err})
+		}
+		for _, file := range files {
+			if seen[file] {
+				continue
 			}
+			seen[file] = true
+			p := plannedCopy{origin: OriginCopyIgnored, conflict: cfg.ConflictPolicyFor(config.CopyEntry{}), sourcePath: filepath.Join(srcRoot, filepath.FromSlash(file)), sourceRel: file, relativePath: file}
+			if err := checkSource(srcRoot, cfg, p.sourcePath); err != nil {
+				p.err = err
+			}
+			planned = append(planned, p)
+		}
+	}
+
+	for _, exclude := range cfg.ExcludeFiles {
+		planned = dropMatching(planned, exclude)
+	}
+
+	return planned
+}
+
+func dropMatching(planned []plannedCopy, pattern string) []plannedCopy {
+	pattern = path.Clean(filepath.ToSlash(pattern))
+
+	kept := planned[:0]
+	for _, p := range planned {
+		name := p.sourceRel
+		if name == "" {
+			name = p.relativePath
+		}
+		if p.err == nil && matchesPathOrParent(pattern, name) {
+			continue
 		}
+		kept = append(kept, p)
+	}
+	return kept
+}
+
+// Options tune FilesFromConfigWithOptions.
+type Options struct {
+	// Workers is the number of files copied concurrently. Zero uses
+	// copy_workers from the configuration or the number of CPUs.
+	Workers int
+
+	// Progress receives an event each time a file is done. Events are sent
+	// in order and the channel is not closed.
+	Progress chan<- Progress
+
+	// Template describes the worktree that template entries are rendered
+	// for. Without it only the target path and its base name are known.
+	Template *TemplateContext
+
+	// Overlay is a directory whose whole tree is copied onto the worktree
+	// after the configured files, replacing copies to the same paths.
+	Overlay string
+
+	// Verify compares the checksum of every written file with its source,
+	// as verify_copies does.
+	Verify bool
+
+	// Vault holds secret files that are decrypted into the worktree last,
+	// replacing copies to the same paths.
+	Vault *vault.Vault
+}
+
+// Progress tells how far a copy has got.
+type Progress struct {
+	Path       string
+	FilesDone  int
+	TotalFiles int
+	BytesDone  int64
+	TotalBytes int64
+}
+
+func (o Options) workerCount(cfg *config.Config, files int) int {
+	workers := o.Workers
+	if workers <= 0 {
+		workers = cfg.CopyWorkers
+	}
+	if workers <= 0 {
+		workers = runtime.NumCPU()
+	}
+	return max(1, min(workers, files))
+}
+
+func FilesFromConfig(srcRoot, targetRoot string, cfg *config.Config) *Report {
+	return FilesFromConfigWithOptions(srcRoot, targetRoot, cfg, Options{})
+}
+
+// FilesFromConfigWithOptions copies the configured files with a pool of
+// workers. Results are reported in plan order whatever order the workers
+// finish in.
+func FilesFromConfigWithOptions(srcRoot, targetRoot string, cfg *config.Config, opts Options) *Report {
+	start := time.Now()
+	planned := applyOverlay(planCopies(srcRoot, cfg), planOverlay(opts.Overlay, cfg))
+	planned = applyOverlay(planned, planVault(opts.Vault, cfg))
+	copier := newCopier(cfg)
+	copier.verify = copier.verify || opts.Verify
+	results := make([]Result, len(planned))
+
+	templateCtx := opts.Template
+	if templateCtx == nil {
+		templateCtx = defaultTemplateContext(targetRoot)
 	}
 
-	if r.SuccessCount > 0 {
-		fmt.Println("\n📋 Successfully copied files:")
-		for _, result := range r.Results {
-			if result.Success {
-				relativeTarget := strings.TrimPrefix(result.TargetPath, result.TargetPath[:strings.LastIndex(result.TargetPath, "/")+1])
-				fmt.Printf("   • %s\n", relativeTarget)
+	progress := Progress{TotalFiles: len(planned)}
+	sizes := make([]int64, len(planned))
+	for i, p := range planned {
+		if info, err := os.Stat(p.sourcePath); p.err == nil && err == nil && info.Mode().IsRegular() {
+			sizes[i] = info.Size()
+			progress.TotalBytes += sizes[i]
+		}
+	}
+
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	jobs := make(chan int)
+	for range opts.workerCount(cfg, len(planned)) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				fileStart := time.Now()
+				results[i] = copier.copyPlanned(targetRoot, planned[i], templateCtx)
+				results[i].Duration = time.Since(fileStart)
+				if opts.Progress == nil {
+					continue
+				}
+
+				mu.Lock()
+				progress.Path = results[i].TargetPath
+				progress.FilesDone++
+				progress.BytesDone += sizes[i]
+				opts.Progress <- progress
+				mu.Unlock()
 			}
+		}()
+	}
+	for i := range planned {
+		jobs <- i
+	}
+	close(jobs)
+	wg.Wait()
+
+	report := &Report{SourceRoot: srcRoot, TargetRoot: targetRoot}
+	for _, result := range results {
+		report.AddResult(result)
+	}
+
+	if err := copier.finish(); err != nil {
+		report.AddResult(Result{SourcePath: srcRoot, TargetPath: targetRoot, Success: false, Error: err})
+	}
+
+	report.Duration = time.Since(start)
+	return report
+}
+
+func (c *copier) copyPlanned(targetRoot string, planned plannedCopy, templateCtx *TemplateContext) Result {
+	result := Result{
+		SourcePath:   planned.sourcePath,
+		TargetPath:   filepath.Join(targetRoot, filepath.FromSlash(planned.relativePath)),
+		RelativePath: planned.relativePath,
+		Pattern:      planned.pattern,
+		Origin:       planned.origin,
+		Mode:         planned.mode,
+		Required:     planned.required,
+		Template:     planned.template,
+	}
+	if len(planned.env) > 0 {
+		result.EnvKeys = envKeys(planned.env)
+	}
+	if result.Mode == "" {
+		result.Mode = config.CopyModeCopy
+	}
+
+	// Rendered content is produced once, as vault files need a decryption
+	var content []byte
+	var err error
+
+	_, statErr := os.Lstat(result.SourcePath)
+	if planned.optional && (planned.err != nil || statErr != nil) {
+		result.Success = true
+		result.Skipped = true
+		result.SkipReason = SkipReasonOptionalMissing
+	} else if planned.err != nil {
+		result.Success = false
+		result.Error = planned.err
+	} else if statErr != nil {
+		result.Success = false
+		result.Error = fmt.Errorf("source file does not exist: %s", result.SourcePath)
+	} else if err = checkTarget(targetRoot, result.TargetPath); err != nil {
+		result.Success = false
+		result.Error = err
+	} else if content, err = renderedContent(planned, templateCtx); err != nil {
+		result.Success = false
+		result.Error = err
+	} else if c.unchanged(planned, result.TargetPath, result.Mode, content) {
+		result.Success = true
+		result.Status = StatusUnchanged
+	} else if existed, err := prepareTarget(&result, planned.conflict); err != nil {
+		result.Success = false
+		result.Error = err
+	} else if result.Skipped {
+		result.Success = true
+	} else if planned.rendered() {
+		if status, err := c.renderToFile(result.SourcePath, result.TargetPath, content); err != nil {
+			result.Success = false
+			result.Error = err
+		} else {
+			result.Success = true
+			result.Status = status
+			result.OverwroteTracked = existed && isTracked(targetRoot, planned.relativePath)
+		}
+	} else {
+		mode, err := c.place(result.SourcePath, result.TargetPath, result.Mode)
+		result.Mode = mode
+		if err == nil {
+			result.Status, err = c.verifyPlaced(result.SourcePath, result.TargetPath, mode)
+		}
+		if err != nil {
+			result.Success = false
+			result.Error = err
+		} else {
+			result.Success = true
+			result.OverwroteTracked = existed && isTracked(targetRoot, planned.relativePath)
 		}
 	}
+
+	if result.Success && !result.Skipped && (result.Mode == config.CopyModeCopy || result.Mode == config.CopyModeClone) {
+		if info, err := os.Lstat(result.TargetPath); err == nil && info.Mode().IsRegular() {
+			result.Bytes = info.Size()
+		}
+	}
+
+	return result
 }
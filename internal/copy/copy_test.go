@@ -1,8 +1,11 @@
 package copy
 
 import (
+	"fmt"
 	"os"
 	"path/filepath"
+	"runtime"
+	"strings"
 	"testing"
 
 	"github.com/daisuke310vvv/sproutee/internal/config"
@@ -119,7 +122,7 @@ func TestFilesFromConfig(t *testing.T) {
 	}
 
 	cfg := &config.Config{
-		CopyFiles: []string{existingFile, nonExistentFile},
+		CopyFiles: config.CopyEntries(existingFile, nonExistentFile),
 	}
 
 	report := FilesFromConfig(srcRoot, targetRoot, cfg)
@@ -181,3 +184,316 @@ func TestReport_AddResult(t *testing.T) {
 		t.Errorf("Results length = %d, want 2", len(report.Results))
 	}
 }
+
+func writeTree(t *testing.T, root string, files map[string]string) {
+	t.Helper()
+	for name, content := range files {
+		full := filepath.Join(root, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(full), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(full, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestFilesFromConfigPatterns(t *testing.T) {
+	tempDir := t.TempDir()
+	srcRoot := filepath.Join(tempDir, "src")
+	targetRoot := filepath.Join(tempDir, "target")
+
+	writeTree(t, srcRoot, map[string]string{
+		".env":                         "A=1",
+		".env.local":                   "B=2",
+		"config/app.local.yml":         "app",
+		"config/dev/db.local.yml":      "db",
+		"config/app.yml":               "tracked",
+		"fixtures/users.json":          "users",
+		"fixtures/large/dump.sql":      "dump",
+		"fixtures/secret/private.json": "secret",
+	})
+
+	cfg := &config.Config{
+		CopyFiles:    config.CopyEntries(".env*", "config/**/*.local.yml", "fixtures", "!fixtures/secret"),
+		ExcludeFiles: []string{"fixtures/large"},
+	}
+
+	report := FilesFromConfig(srcRoot, targetRoot, cfg)
+	if report.FailureCount != 0 {
+		t.Fatalf("FailureCount = %d, want 0: %+v", report.FailureCount, report.Results)
+	}
+
+	want := map[string]string{
+		".env":                    ".env*",
+		".env.local":              ".env*",
+		"config/app.local.yml":    "config/**/*.local.yml",
+		"config/dev/db.local.yml": "config/**/*.local.yml",
+		"fixtures/users.json":     "fixtures",
+	}
+	if report.SuccessCount != len(want) {
+		t.Errorf("SuccessCount = %d, want %d", report.SuccessCount, len(want))
+	}
+
+	for _, result := range report.Results {
+		rel, err := filepath.Rel(targetRoot, result.TargetPath)
+		if err != nil {
+			t.Fatal(err)
+		}
+		pattern, ok := want[filepath.ToSlash(rel)]
+		if !ok {
+			t.Errorf("unexpected file copied: %s", rel)
+			continue
+		}
+		if result.Pattern != pattern {
+			t.Errorf("Pattern for %s = %s, want %s", rel, result.Pattern, pattern)
+		}
+	}
+
+	for _, name := range []string{"config/app.yml", "fixtures/large/dump.sql", "fixtures/secret/private.json"} {
+		if FileExists(filepath.Join(targetRoot, filepath.FromSlash(name))) {
+			t.Errorf("%s should not have been copied", name)
+		}
+	}
+}
+
+func TestFilesFromConfigPatternWithoutMatches(t *testing.T) {
+	tempDir := t.TempDir()
+	srcRoot := filepath.Join(tempDir, "src")
+	if err := os.MkdirAll(srcRoot, 0o755); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg := &config.Config{CopyFiles: config.CopyEntries("config/**/*.local.yml")}
+	report := FilesFromConfig(srcRoot, filepath.Join(tempDir, "target"), cfg)
+
+	if report.FailureCount != 1 {
+		t.Errorf("FailureCount = %d, want 1 for a pattern without matches", report.FailureCount)
+	}
+}
+
+func TestFileWithMode(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("symlinks need privileges on Windows")
+	}
+
+	tempDir := t.TempDir()
+	src := filepath.Join(tempDir, "src", "data.db")
+	writeTree(t, filepath.Join(tempDir, "src"), map[string]string{"data.db": "data"})
+
+	for _, mode := range []string{config.CopyModeCopy, config.CopyModeSymlink, config.CopyModeHardlink, config.CopyModeClone} {
+		dst := filepath.Join(tempDir, mode, "data.db")
+		used, err := FileWithMode(src, dst, mode)
+		if err != nil {
+			t.Fatalf("FileWithMode(%s) error = %v", mode, err)
+		}
+
+		content, err := os.ReadFile(dst)
+		if err != nil || string(content) != "data" {
+			t.Errorf("FileWithMode(%s) content = %q, %v", mode, content, err)
+		}
+
+		info, err := os.Lstat(dst)
+		if err != nil {
+			t.Fatal(err)
+		}
+		srcInfo, err := os.Stat(src)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		switch mode {
+		case config.CopyModeSymlink:
+			if info.Mode()&os.ModeSymlink == 0 {
+				t.Error("symlink mode should create a symbolic link")
+			}
+		case config.CopyModeHardlink:
+			if !os.SameFile(info, srcInfo) {
+				t.Error("hardlink mode should link to the source file")
+			}
+		case config.CopyModeClone:
+			if used != config.CopyModeClone && used != config.CopyModeCopy {
+				t.Errorf("clone mode used %s", used)
+			}
+		default:
+			if os.SameFile(info, srcInfo) {
+				t.Error("copy mode should create an independent file")
+			}
+		}
+	}
+}
+
+func TestFilesFromConfigModes(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("symlinks need privileges on Windows")
+	}
+
+	tempDir := t.TempDir()
+	srcRoot := filepath.Join(tempDir, "src")
+	targetRoot := filepath.Join(tempDir, "target")
+	writeTree(t, srcRoot, map[string]string{".env": "A=1", "fixtures/users.json": "users"})
+
+	cfg := &config.Config{CopyFiles: []config.CopyEntry{
+		{From: ".env"},
+		{From: "fixtures", Mode: config.CopyModeSymlink},
+	}}
+	report := FilesFromConfig(srcRoot, targetRoot, cfg)
+	if report.FailureCount != 0 {
+		t.Fatalf("FailureCount = %d, want 0: %+v", report.FailureCount, report.Results)
+	}
+
+	modes := map[string]string{}
+	for _, result := range report.Results {
+		modes[filepath.Base(result.TargetPath)] = result.Mode
+	}
+	if modes[".env"] != config.CopyModeCopy || modes["users.json"] != config.CopyModeSymlink {
+		t.Errorf("Result modes = %v", modes)
+	}
+}
+
+func TestFilesFromConfigMapping(t *testing.T) {
+	tempDir := t.TempDir()
+	srcRoot := filepath.Join(tempDir, "src")
+	targetRoot := filepath.Join(tempDir, "target")
+	external := filepath.Join(tempDir, "secrets")
+
+	writeTree(t, srcRoot, map[string]string{
+		"config/.env.worktree": "WORKTREE=1",
+		"templates/a.yml":      "a",
+		"templates/sub/b.yml":  "b",
+	})
+	writeTree(t, external, map[string]string{"app.env": "SECRET=1"})
+
+	cfg := &config.Config{
+		CopyFiles: []config.CopyEntry{
+			{From: "config/.env.worktree", To: ".env"},
+			{From: "templates", To: "config/generated"},
+			{From: filepath.Join(external, "app.env"), To: "secrets/"},
+			{From: "missing.json", Optional: true},
+		},
+		AllowedExternalSources: []string{external},
+	}
+
+	report := FilesFromConfig(srcRoot, targetRoot, cfg)
+	if report.FailureCount != 0 || report.SuccessCount != 4 || report.SkippedCount != 1 {
+		t.Fatalf("FilesFromConfig() = %d successes, %d skipped, %d failures, want 4, 1 and 0: %+v", report.SuccessCount, report.SkippedCount, report.FailureCount, report.Results)
+	}
+
+	want := map[string]string{
+		".env":                       "WORKTREE=1",
+		"config/generated/a.yml":     "a",
+		"config/generated/sub/b.yml": "b",
+		"secrets/app.env":            "SECRET=1",
+	}
+	for name, content := range want {
+		data, err := os.ReadFile(filepath.Join(targetRoot, filepath.FromSlash(name)))
+		if err != nil || string(data) != content {
+			t.Errorf("%s = %q, %v, want %q", name, data, err, content)
+		}
+	}
+}
+
+func TestFilesFromConfigExternalNotAllowed(t *testing.T) {
+	tempDir := t.TempDir()
+	srcRoot := filepath.Join(tempDir, "src")
+	targetRoot := filepath.Join(tempDir, "target")
+	writeTree(t, srcRoot, map[string]string{"README.md": "readme"})
+	writeTree(t, tempDir, map[string]string{"shared/app.env": "SECRET=1"})
+
+	// Traversal is refused even when the directory is allowed
+	cfg := &config.Config{
+		CopyFiles:              []config.CopyEntry{{From: "../shared/app.env", Optional: true}, {From: "README.md", To: "../escaped.md"}},
+		AllowedExternalSources: []string{filepath.Join(tempDir, "shared")},
+	}
+	report := FilesFromConfig(srcRoot, targetRoot, cfg)
+	if report.FailureCount != 2 {
+		t.Errorf("FailureCount = %d, want 2 for paths leaving the repository or worktree", report.FailureCount)
+	}
+	if FileExists(filepath.Join(tempDir, "escaped.md")) {
+		t.Error("a file was written outside the worktree")
+	}
+
+	cfg.CopyFiles = []config.CopyEntry{{From: filepath.Join(tempDir, "shared", "app.env")}}
+	cfg.AllowedExternalSources = nil
+	report = FilesFromConfig(srcRoot, targetRoot, cfg)
+	if report.FailureCount != 1 {
+		t.Errorf("FailureCount = %d, want 1 for a source that is not allowed", report.FailureCount)
+	}
+
+	cfg.AllowedExternalSources = []string{filepath.Join(tempDir, "shared")}
+	report = FilesFromConfig(srcRoot, targetRoot, cfg)
+	if report.SuccessCount != 1 || report.FailureCount != 0 {
+		t.Errorf("FilesFromConfig() = %d successes, %d failures, want 1 and 0", report.SuccessCount, report.FailureCount)
+	}
+}
+
+func TestFilesFromConfigWithOptionsParallel(t *testing.T) {
+	tempDir := t.TempDir()
+	srcRoot := filepath.Join(tempDir, "src")
+	targetRoot := filepath.Join(tempDir, "target")
+
+	files := make(map[string]string)
+	var entries []string
+	for i := range 50 {
+		name := fmt.Sprintf("assets/file%02d.txt", i)
+		files[name] = strings.Repeat("x", i+1)
+		entries = append(entries, name)
+	}
+	writeTree(t, srcRoot, files)
+
+	progress := make(chan Progress, len(entries))
+	report := FilesFromConfigWithOptions(srcRoot, targetRoot, &config.Config{CopyFiles: config.CopyEntries(entries...)}, Options{Workers: 8, Progress: progress})
+	close(progress)
+
+	if report.SuccessCount != len(entries) {
+		t.Fatalf("SuccessCount = %d, want %d", report.SuccessCount, len(entries))
+	}
+	for i, result := range report.Results {
+		if want := filepath.Join(targetRoot, filepath.FromSlash(entries[i])); result.TargetPath != want {
+			t.Errorf("Results[%d] = %s, want %s", i, result.TargetPath, want)
+		}
+	}
+
+	var events []Progress
+	for p := range progress {
+		events = append(events, p)
+	}
+	if len(events) != len(entries) {
+		t.Fatalf("got %d progress events, want %d", len(events), len(entries))
+	}
+	last := events[len(events)-1]
+	if last.FilesDone != len(entries) || last.TotalFiles != len(entries) || last.BytesDone != last.TotalBytes || last.TotalBytes != 50*51/2 {
+		t.Errorf("last progress = %+v", last)
+	}
+	for i := 1; i < len(events); i++ {
+		if events[i].FilesDone != events[i-1].FilesDone+1 || events[i].BytesDone < events[i-1].BytesDone {
+			t.Errorf("progress events out of order at %d: %+v after %+v", i, events[i], events[i-1])
+		}
+	}
+}
+
+func TestFilesFromConfigRequiredAndOptional(t *testing.T) {
+	tempDir := t.TempDir()
+	srcRoot := filepath.Join(tempDir, "src")
+	writeTree(t, srcRoot, map[string]string{".env": "A=1"})
+
+	cfg := &config.Config{CopyFiles: []config.CopyEntry{
+		{From: ".env", Required: true},
+		{From: ".env.local", Optional: true},
+		{From: "config/master.key", Required: true},
+		{From: "Makefile.local"},
+	}}
+	report := FilesFromConfig(srcRoot, filepath.Join(tempDir, "target"), cfg)
+
+	if report.SuccessCount != 1 || report.SkippedCount != 1 || report.FailureCount != 2 {
+		t.Fatalf("FilesFromConfig() = %d successes, %d skipped, %d failures, want 1, 1 and 2", report.SuccessCount, report.SkippedCount, report.FailureCount)
+	}
+	if reason := report.Results[1].SkipReason; reason != SkipReasonOptionalMissing {
+		t.Errorf("SkipReason = %q, want %q", reason, SkipReasonOptionalMissing)
+	}
+
+	failures := report.RequiredFailures()
+	if len(failures) != 1 || failures[0].Pattern != "config/master.key" {
+		t.Errorf("RequiredFailures() = %+v, want only config/master.key", failures)
+	}
+}
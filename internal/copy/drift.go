@@ -0,0 +1,94 @@
+package copy
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/daisuke310vvv/sproutee/internal/config"
+)
+
+// Drift states of a copied file.
+const (
+	DriftInSync   = "in sync"
+	DriftModified = "modified"
+	DriftMissing  = "missing"
+)
+
+// Drift compares a configured file with its copy in a worktree.
+type Drift struct {
+	SourcePath string
+	TargetPath string
+	Pattern    string
+	Status     string
+}
+
+// CheckDrift compares the files the configuration copies with their copies in
+// targetRoot by SHA-256 checksum. Template and env override entries are
+// compared with their rendering for templateCtx. Sources that cannot be
+// resolved are left out, as there is nothing to compare.
+func CheckDrift(srcRoot, targetRoot string, cfg *config.Config, templateCtx *TemplateContext) ([]Drift, error) {
+	if templateCtx == nil {
+		templateCtx = defaultTemplateContext(targetRoot)
+	}
+
+	var drifts []Drift
+	for _, planned := range planCopies(srcRoot, cfg) {
+		if planned.err != nil {
+			continue
+		}
+
+		drift := Drift{
+			SourcePath: planned.sourcePath,
+			TargetPath: filepath.Join(targetRoot, filepath.FromSlash(planned.relativePath)),
+			Pattern:    planned.pattern,
+		}
+
+		var sourceSum string
+		if planned.rendered() {
+			content, err := plannedContent(drift.SourcePath, planned, templateCtx)
+			if err != nil {
+				return nil, err
+			}
+			sourceSum = contentChecksum(content)
+		} else {
+			sum, err := fileChecksum(drift.SourcePath)
+			if err != nil {
+				return nil, fmt.Errorf("failed to read source file: %w", err)
+			}
+			sourceSum = sum
+		}
+		targetSum, err := fileChecksum(drift.TargetPath)
+		switch {
+		case os.IsNotExist(err):
+			drift.Status = DriftMissing
+		case err != nil:
+			return nil, fmt.Errorf("failed to read target file: %w", err)
+		case sourceSum == targetSum:
+			drift.Status = DriftInSync
+		default:
+			drift.Status = DriftModified
+		}
+
+		drifts = append(drifts, drift)
+	}
+	return drifts, nil
+}
+
+// fileChecksum returns the hex-encoded SHA-256 checksum of a file's content.
+func fileChecksum(path string) (string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+
+	hash := sha256.New()
+	if _, err := io.Copy(hash, file); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
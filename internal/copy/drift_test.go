@@ -0,0 +1,32 @@
+package copy
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/daisuke310vvv/sproutee/internal/config"
+)
+
+func TestCheckDrift(t *testing.T) {
+	tempDir := t.TempDir()
+	srcRoot := filepath.Join(tempDir, "src")
+	targetRoot := filepath.Join(tempDir, "target")
+	writeTree(t, srcRoot, map[string]string{".env": "SECRET=rotated", "Makefile": "all:", "config/app.yml": "app"})
+	writeTree(t, targetRoot, map[string]string{".env": "SECRET=old", "Makefile": "all:"})
+
+	cfg := &config.Config{CopyFiles: config.CopyEntries(".env", "Makefile", "config/app.yml", "missing.txt")}
+	drifts, err := CheckDrift(srcRoot, targetRoot, cfg, nil)
+	if err != nil {
+		t.Fatalf("CheckDrift() error = %v", err)
+	}
+
+	want := map[string]string{".env": DriftModified, "Makefile": DriftInSync, "app.yml": DriftMissing}
+	if len(drifts) != len(want) {
+		t.Fatalf("CheckDrift() returned %d entries, want %d: %+v", len(drifts), len(want), drifts)
+	}
+	for _, drift := range drifts {
+		if status := want[filepath.Base(drift.TargetPath)]; drift.Status != status {
+			t.Errorf("Status of %s = %s, want %s", drift.TargetPath, drift.Status, status)
+		}
+	}
+}
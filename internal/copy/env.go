@@ -0,0 +1,39 @@
+package copy
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+	"text/template"
+
+	"github.com/daisuke310vvv/sproutee/internal/dotenv"
+)
+
+// applyEnv sets the keys of overrides in the dotenv content, keeping the rest
+// of the file as it is. Values are templates rendered with ctx, e.g.
+// "postgres://localhost/app_{{.Name}}" or "{{.Ports.web}}".
+func applyEnv(content []byte, overrides map[string]string, ctx *TemplateContext) ([]byte, error) {
+	file := dotenv.Parse(content)
+	for _, key := range envKeys(overrides) {
+		tmpl, err := template.New(key).Option("missingkey=error").Parse(overrides[key])
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse env override '%s': %w", key, err)
+		}
+
+		var value bytes.Buffer
+		if err := tmpl.Execute(&value, ctx); err != nil {
+			return nil, fmt.Errorf("failed to render env override '%s': %w", key, err)
+		}
+		file.Set(key, value.String())
+	}
+	return file.Bytes(), nil
+}
+
+func envKeys(overrides map[string]string) []string {
+	keys := make([]string, 0, len(overrides))
+	for key := range overrides {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,58 @@
+package copy
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/daisuke310vvv/sproutee/internal/config"
+)
+
+func TestFilesFromConfigEnvOverrides(t *testing.T) {
+	tempDir := t.TempDir()
+	srcRoot := filepath.Join(tempDir, "src")
+	targetRoot := filepath.Join(tempDir, "target")
+	writeTree(t, srcRoot, map[string]string{
+		".env":    "# local settings\nexport DATABASE_URL=\"postgres://localhost/app\" # dev\nPORT=3000\nSECRET='keep'\n",
+		"bad.env": "PORT=3000\n",
+	})
+
+	cfg := &config.Config{CopyFiles: []config.CopyEntry{
+		{From: ".env", Env: map[string]string{
+			"DATABASE_URL": "postgres://localhost/app_{{.Name}}",
+			"PORT":         "{{.Ports.web}}",
+			"WORKTREE":     "{{.Index}}",
+		}},
+		{From: "bad.env", Env: map[string]string{"PORT": "{{.Ports.api}}"}},
+	}}
+	opts := Options{Template: NewTemplateContext("feature", "feature", targetRoot, 2, map[string]int{"web": 3000})}
+	report := FilesFromConfigWithOptions(srcRoot, targetRoot, cfg, opts)
+
+	if report.SuccessCount != 1 || report.FailureCount != 1 {
+		t.Fatalf("FilesFromConfigWithOptions() = %d successes, %d failures, want 1 and 1: %+v", report.SuccessCount, report.FailureCount, report.Results)
+	}
+	if err := report.Results[1].Error; err == nil || !strings.Contains(err.Error(), "env override 'PORT'") {
+		t.Errorf("override error = %v, want a render error for PORT", err)
+	}
+	if got := strings.Join(report.Results[0].EnvKeys, ","); got != "DATABASE_URL,PORT,WORKTREE" {
+		t.Errorf("EnvKeys = %s", got)
+	}
+
+	content, err := os.ReadFile(filepath.Join(targetRoot, ".env"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "# local settings\nexport DATABASE_URL=\"postgres://localhost/app_feature\" # dev\nPORT=3002\nSECRET='keep'\nWORKTREE=2\n"
+	if string(content) != want {
+		t.Errorf(".env = %q, want %q", content, want)
+	}
+
+	drifts, err := CheckDrift(srcRoot, targetRoot, &config.Config{CopyFiles: cfg.CopyFiles[:1]}, opts.Template)
+	if err != nil {
+		t.Fatalf("CheckDrift() error = %v", err)
+	}
+	if len(drifts) != 1 || drifts[0].Status != DriftInSync {
+		t.Errorf("CheckDrift() = %+v, want the overridden file in sync", drifts)
+	}
+}
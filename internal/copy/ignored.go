@@ -0,0 +1,133 @@
+package copy
+
+import (
+	"fmt"
+	"os/exec"
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/daisuke310vvv/sproutee/internal/config"
+)
+
+// DefaultSkipDirs are directories that are never searched for ignored files
+// unless copy_ignored.skip_dirs overrides them. They are usually large and
+// rebuilt by init scripts anyway.
+var DefaultSkipDirs = []string{
+	"node_modules",
+	"vendor",
+	".venv",
+	"venv",
+	"__pycache__",
+	"Pods",
+	"DerivedData",
+	".gradle",
+	".next",
+	".nuxt",
+	".cache",
+	".terraform",
+	"dist",
+	"build",
+	"target",
+	"coverage",
+}
+
+// FindIgnoredFiles returns the slash-separated paths of files in srcRoot that
+// are ignored by Git, filtered by the include and exclude patterns and the
+// skipped directories of the configuration.
+func FindIgnoredFiles(srcRoot string, cfg *config.CopyIgnoredConfig) ([]string, error) {
+	cmd := exec.Command("git", "ls-files", "--others", "--ignored", "--exclude-standard", "--directory", "-z")
+	cmd.Dir = srcRoot
+
+	output, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list ignored files: %w", err)
+	}
+
+	skipDirs := DefaultSkipDirs
+	if cfg.SkipDirs != nil {
+		skipDirs = cfg.SkipDirs
+	}
+
+	var files []string
+	for _, entry := range strings.Split(string(output), "\x00") {
+		if entry == "" || inSkippedDir(entry, skipDirs) {
+			continue
+		}
+
+		if !strings.HasSuffix(entry, "/") {
+			files = append(files, entry)
+			continue
+		}
+
+		nested, err := walkFiles(srcRoot, strings.TrimSuffix(entry, "/"))
+		if err != nil {
+			return nil, fmt.Errorf("failed to read ignored directory: %w", err)
+		}
+		for _, file := range nested {
+			if !inSkippedDir(file, skipDirs) {
+				files = append(files, file)
+			}
+		}
+	}
+
+	var selected []string
+	seen := make(map[string]bool)
+	for _, file := range files {
+		if seen[file] {
+			continue
+		}
+		seen[file] = true
+
+		if len(cfg.Include) > 0 && !matchesAny(cfg.Include, file) {
+			continue
+		}
+		if matchesAnyPathOrParent(cfg.Exclude, file) {
+			continue
+		}
+		selected = append(selected, file)
+	}
+
+	sort.Strings(selected)
+	return selected, nil
+}
+
+func inSkippedDir(name string, skipDirs []string) bool {
+	segments := strings.Split(strings.TrimSuffix(name, "/"), "/")
+	for _, segment := range segments[:len(segments)-1] {
+		for _, dir := range skipDirs {
+			if segment == dir {
+				return true
+			}
+		}
+	}
+
+	// Directories reported by git end with a slash; their last segment counts too
+	if strings.HasSuffix(name, "/") {
+		last := segments[len(segments)-1]
+		for _, dir := range skipDirs {
+			if last == dir {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func matchesAny(patterns []string, name string) bool {
+	for _, pattern := range patterns {
+		if MatchPattern(path.Clean(pattern), name) {
+			return true
+		}
+	}
+	return false
+}
+
+func matchesAnyPathOrParent(patterns []string, name string) bool {
+	for _, pattern := range patterns {
+		if matchesPathOrParent(path.Clean(pattern), name) {
+			return true
+		}
+	}
+	return false
+}
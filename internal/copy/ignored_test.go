@@ -0,0 +1,83 @@
+package copy
+
+import (
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/daisuke310vvv/sproutee/internal/config"
+)
+
+func TestInSkippedDir(t *testing.T) {
+	tests := []struct {
+		name string
+		want bool
+	}{
+		{"node_modules/", true},
+		{"web/node_modules/", true},
+		{"web/node_modules/pkg/index.js", true},
+		{".env", false},
+		{"config/local.properties", false},
+		{"node_modules.txt", false},
+	}
+
+	for _, tt := range tests {
+		if got := inSkippedDir(tt.name, DefaultSkipDirs); got != tt.want {
+			t.Errorf("inSkippedDir(%q) = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestFindIgnoredFiles(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git is not available")
+	}
+
+	srcRoot := t.TempDir()
+	if output, err := exec.Command("git", "init", "-q", srcRoot).CombinedOutput(); err != nil {
+		t.Fatalf("git init failed: %v\n%s", err, output)
+	}
+
+	writeTree(t, srcRoot, map[string]string{
+		".gitignore":                   ".env\n*.xcconfig\nlocal.properties\nnode_modules/\nsecrets/\n*.log\n",
+		".env":                         "A=1",
+		"app/Debug.xcconfig":           "debug",
+		"local.properties":             "sdk.dir=/sdk",
+		"node_modules/pkg/index.js":    "module",
+		"secrets/api.key":              "key",
+		"debug.log":                    "log",
+		"tracked.txt":                  "tracked",
+		"secrets/node_modules/skip.js": "skip",
+	})
+
+	files, err := FindIgnoredFiles(srcRoot, &config.CopyIgnoredConfig{Enabled: true, Exclude: []string{"*.log"}})
+	if err != nil {
+		t.Fatalf("FindIgnoredFiles() error = %v", err)
+	}
+
+	want := []string{".env", "app/Debug.xcconfig", "local.properties", "secrets/api.key"}
+	if !reflect.DeepEqual(files, want) {
+		t.Errorf("FindIgnoredFiles() = %v, want %v", files, want)
+	}
+
+	files, err = FindIgnoredFiles(srcRoot, &config.CopyIgnoredConfig{Enabled: true, Include: []string{"**/*.xcconfig"}})
+	if err != nil {
+		t.Fatalf("FindIgnoredFiles() error = %v", err)
+	}
+	if !reflect.DeepEqual(files, []string{"app/Debug.xcconfig"}) {
+		t.Errorf("FindIgnoredFiles() with include = %v, want [app/Debug.xcconfig]", files)
+	}
+
+	targetRoot := filepath.Join(t.TempDir(), "target")
+	report := FilesFromConfig(srcRoot, targetRoot, &config.Config{
+		CopyFiles:   config.CopyEntries(".env"),
+		CopyIgnored: &config.CopyIgnoredConfig{Enabled: true, Include: []string{".env", "local.properties"}},
+	})
+	if report.SuccessCount != 2 || report.FailureCount != 0 {
+		t.Fatalf("FilesFromConfig() = %d successes, %d failures, want 2 and 0", report.SuccessCount, report.FailureCount)
+	}
+	if report.Results[0].Origin != OriginCopyFiles || report.Results[1].Origin != OriginCopyIgnored {
+		t.Errorf("Result origins = %s, %s, want copy_files then copy_ignored", report.Results[0].Origin, report.Results[1].Origin)
+	}
+}
@@ -0,0 +1,116 @@
+package copy
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/daisuke310vvv/sproutee/internal/config"
+)
+
+// errCloneUnsupported is returned by cloneFile when the platform or file
+// system cannot share extents between files.
+var errCloneUnsupported = errors.New("cloning is not supported")
+
+// FileWithMode places src at dst using the given copy mode and returns the
+// mode that was actually used. Clones fall back to a regular copy when the
+// file system does not support them. Like File, every mode creates the
+// target under a temporary name and renames it over dst.
+func FileWithMode(src, dst, mode string) (string, error) {
+	return (&copier{}).place(src, dst, mode)
+}
+
+func (c *copier) place(src, dst, mode string) (string, error) {
+	if mode == "" {
+		mode = config.CopyModeCopy
+	}
+
+	if err := c.mkdirParents(src, dst); err != nil {
+		return mode, err
+	}
+
+	faithful := mode == config.CopyModeCopy || mode == config.CopyModeClone
+	if faithful && c.preserve.Symlinks {
+		if info, err := os.Lstat(src); err == nil && info.Mode()&os.ModeSymlink != 0 {
+			return mode, copySymlink(src, dst)
+		}
+	}
+
+	// Stat before reading, which updates the access time of the source
+	srcInfo, err := os.Stat(src)
+	if err != nil {
+		return mode, fmt.Errorf("failed to stat source file: %w", err)
+	}
+
+	used, err := placeFile(src, dst, mode)
+	if err != nil || !faithful {
+		return used, err
+	}
+	return used, c.copyMetadata(src, dst, srcInfo)
+}
+
+func placeFile(src, dst, mode string) (string, error) {
+	if mode == config.CopyModeCopy {
+		return mode, File(src, dst)
+	}
+
+	tempPath, err := reserveTempPath(dst)
+	if err != nil {
+		return mode, err
+	}
+
+	switch mode {
+	case config.CopyModeSymlink:
+		absSrc, err := filepath.Abs(src)
+		if err != nil {
+			return mode, fmt.Errorf("failed to resolve source path: %w", err)
+		}
+		if err := os.Symlink(absSrc, tempPath); err != nil {
+			return mode, fmt.Errorf("failed to create symlink: %w", err)
+		}
+	case config.CopyModeHardlink:
+		if err := os.Link(src, tempPath); err != nil {
+			return mode, fmt.Errorf("failed to create hard link: %w", err)
+		}
+	case config.CopyModeClone:
+		err := cloneFile(src, tempPath)
+		if errors.Is(err, errCloneUnsupported) {
+			return config.CopyModeCopy, File(src, dst)
+		}
+		if err != nil {
+			return mode, err
+		}
+	default:
+		return mode, fmt.Errorf("unknown copy mode: %s", mode)
+	}
+
+	return mode, replaceFile(tempPath, dst)
+}
+
+func tempPattern(dst string) string {
+	return "." + filepath.Base(dst) + ".sproutee-*"
+}
+
+// reserveTempPath returns an unused path next to dst where a link or clone
+// can be created before it is renamed over dst.
+func reserveTempPath(dst string) (string, error) {
+	file, err := os.CreateTemp(filepath.Dir(dst), tempPattern(dst))
+	if err != nil {
+		return "", fmt.Errorf("failed to create target file: %w", err)
+	}
+	tempPath := file.Name()
+	_ = file.Close()
+	if err := os.Remove(tempPath); err != nil {
+		return "", fmt.Errorf("failed to prepare target file: %w", err)
+	}
+	return tempPath, nil
+}
+
+func replaceFile(tempPath, dst string) error {
+	if err := os.Rename(tempPath, dst); err != nil {
+		_ = os.Remove(tempPath)
+		return fmt.Errorf("failed to move file into place: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,104 @@
+package copy
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+
+	"github.com/daisuke310vvv/sproutee/internal/config"
+	"github.com/daisuke310vvv/sproutee/internal/vault"
+)
+
+// planOverlay lists every file below overlayRoot to be copied to the same
+// relative path in the worktree. A missing overlay directory plans nothing.
+func planOverlay(overlayRoot string, cfg *config.Config) []plannedCopy {
+	if overlayRoot == "" {
+		return nil
+	}
+	if info, err := os.Stat(overlayRoot); err != nil || !info.IsDir() {
+		return nil
+	}
+
+	root, err := filepath.EvalSymlinks(overlayRoot)
+	if err != nil {
+		return []plannedCopy{{origin: OriginOverlay, sourcePath: overlayRoot, relativePath: ".", err: fmt.Errorf("failed to resolve overlay directory: %w", err)}}
+	}
+
+	var planned []plannedCopy
+	conflict := cfg.ConflictPolicyFor(config.CopyEntry{})
+	err = filepath.WalkDir(root, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if entry.IsDir() {
+			return nil
+		}
+
+		relativePath, err := filepath.Rel(root, path)
+		if err != nil {
+			return err
+		}
+		p := plannedCopy{
+			origin:       OriginOverlay,
+			conflict:     conflict,
+			sourcePath:   path,
+			relativePath: filepath.ToSlash(relativePath),
+		}
+
+		// Symlinks in the overlay may only point into it
+		if resolved, err := filepath.EvalSymlinks(path); err == nil && !isWithin(resolved, root) {
+			p.err = fmt.Errorf("overlay file resolves outside the overlay directory: %s", path)
+		}
+		planned = append(planned, p)
+		return nil
+	})
+	if err != nil {
+		planned = append(planned, plannedCopy{origin: OriginOverlay, sourcePath: overlayRoot, relativePath: ".", err: fmt.Errorf("failed to read overlay directory: %w", err)})
+	}
+	return planned
+}
+
+// applyOverlay adds the overlay or vault files to the plan. They are laid
+// over the files planned so far, so they replace planned copies to the same
+// target.
+func applyOverlay(planned, overlay []plannedCopy) []plannedCopy {
+	if len(overlay) == 0 {
+		return planned
+	}
+
+	overlaid := make(map[string]bool, len(overlay))
+	for _, p := range overlay {
+		overlaid[p.relativePath] = p.err == nil
+	}
+
+	kept := planned[:0]
+	for _, p := range planned {
+		if !overlaid[p.relativePath] {
+			kept = append(kept, p)
+		}
+	}
+	return append(kept, overlay...)
+}
+
+// planVault lists the files stored in the vault, to be decrypted to the same
+// relative path in the worktree.
+func planVault(secrets *vault.Vault, cfg *config.Config) []plannedCopy {
+	if secrets == nil {
+		return nil
+	}
+
+	entries, err := secrets.List()
+	if err != nil {
+		return []plannedCopy{{origin: OriginVault, sourcePath: secrets.Dir(), relativePath: ".", err: err}}
+	}
+
+	planned := make([]plannedCopy, 0, len(entries))
+	conflict := cfg.ConflictPolicyFor(config.CopyEntry{})
+	for _, entry := range entries {
+		p := plannedCopy{origin: OriginVault, conflict: conflict, relativePath: entry.Path, secret: secrets}
+		p.sourcePath, p.err = secrets.CiphertextPath(entry.Path)
+		planned = append(planned, p)
+	}
+	return planned
+}
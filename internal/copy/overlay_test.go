@@ -0,0 +1,103 @@
+package copy
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"github.com/daisuke310vvv/sproutee/internal/config"
+	"github.com/daisuke310vvv/sproutee/internal/vault"
+)
+
+func TestFilesFromConfigOverlay(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("symlinks need privileges on Windows")
+	}
+
+	tempDir := t.TempDir()
+	srcRoot := filepath.Join(tempDir, "src")
+	overlay := filepath.Join(tempDir, "overlay")
+	targetRoot := filepath.Join(tempDir, "target")
+	writeTree(t, srcRoot, map[string]string{".env": "FROM=repo"})
+	writeTree(t, overlay, map[string]string{
+		".env":                "FROM=overlay",
+		".idea/workspace.xml": "<project/>",
+		"certs/signing.p12":   "cert",
+	})
+	writeTree(t, targetRoot, map[string]string{"certs/signing.p12": "existing"})
+	if err := os.Symlink(filepath.Join(srcRoot, ".env"), filepath.Join(overlay, "escape")); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg := &config.Config{CopyFiles: config.CopyEntries(".env"), ConflictPolicy: config.ConflictSkip}
+	report := FilesFromConfigWithOptions(srcRoot, targetRoot, cfg, Options{Overlay: overlay})
+
+	if report.TotalFiles != 4 || report.SuccessCount != 2 || report.SkippedCount != 1 || report.FailureCount != 1 {
+		t.Fatalf("FilesFromConfigWithOptions() = %+v", report)
+	}
+	for _, result := range report.Results {
+		if result.Origin != OriginOverlay {
+			t.Errorf("result for %s has origin %s, want the overlay to replace copy_files", result.TargetPath, result.Origin)
+		}
+	}
+
+	tests := map[string]string{
+		".env":                "FROM=overlay",
+		".idea/workspace.xml": "<project/>",
+		"certs/signing.p12":   "existing",
+	}
+	for file, want := range tests {
+		content, err := os.ReadFile(filepath.Join(targetRoot, file))
+		if err != nil || string(content) != want {
+			t.Errorf("%s = %q, %v, want %q", file, content, err, want)
+		}
+	}
+	if _, err := os.Lstat(filepath.Join(targetRoot, "escape")); !os.IsNotExist(err) {
+		t.Errorf("symlink leaving the overlay should not be copied: %v", err)
+	}
+
+	// A missing overlay adds nothing
+	report = FilesFromConfigWithOptions(srcRoot, filepath.Join(tempDir, "other"), cfg, Options{Overlay: filepath.Join(tempDir, "missing")})
+	if report.TotalFiles != 1 || report.Results[0].Origin != OriginCopyFiles {
+		t.Errorf("FilesFromConfigWithOptions() without overlay = %+v", report.Results)
+	}
+}
+
+func TestFilesFromConfigVault(t *testing.T) {
+	tempDir := t.TempDir()
+	srcRoot := filepath.Join(tempDir, "src")
+	targetRoot := filepath.Join(tempDir, "target")
+	writeTree(t, srcRoot, map[string]string{".env": "SECRET=plain"})
+
+	secrets := vault.Open(filepath.Join(tempDir, "vault"), "")
+	if err := secrets.Add(".env", []byte("SECRET=vault\nPORT=3000\n")); err != nil {
+		t.Fatal(err)
+	}
+	if err := secrets.Add("config/master.key", []byte("key")); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg := &config.Config{CopyFiles: []config.CopyEntry{{From: ".env", Env: map[string]string{"PORT": "4000"}}}}
+	report := FilesFromConfigWithOptions(srcRoot, targetRoot, cfg, Options{Vault: secrets})
+
+	if report.TotalFiles != 2 || report.SuccessCount != 2 {
+		t.Fatalf("FilesFromConfigWithOptions() = %+v", report)
+	}
+	for _, result := range report.Results {
+		if result.Origin != OriginVault {
+			t.Errorf("result for %s has origin %s, want %s", result.TargetPath, result.Origin, OriginVault)
+		}
+	}
+
+	tests := map[string]string{
+		".env":              "SECRET=vault\nPORT=3000\n",
+		"config/master.key": "key",
+	}
+	for file, want := range tests {
+		content, err := os.ReadFile(filepath.Join(targetRoot, file))
+		if err != nil || string(content) != want {
+			t.Errorf("%s = %q, %v, want %q", file, content, err, want)
+		}
+	}
+}
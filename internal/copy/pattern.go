@@ -0,0 +1,147 @@
+package copy
+
+import (
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// hasMeta reports whether a copy_files entry is a glob rather than a literal
+// path.
+func hasMeta(pattern string) bool {
+	return strings.ContainsAny(pattern, "*?[")
+}
+
+// MatchPattern reports whether a slash-separated relative path matches the
+// pattern. Patterns are anchored at the repository root; "**" matches any
+// number of directories and every other segment follows path.Match.
+func MatchPattern(pattern, name string) bool {
+	return matchSegments(strings.Split(pattern, "/"), strings.Split(name, "/"))
+}
+
+func matchSegments(pattern, name []string) bool {
+	for len(pattern) > 0 {
+		if pattern[0] == "**" {
+			for len(pattern) > 0 && pattern[0] == "**" {
+				pattern = pattern[1:]
+			}
+			if len(pattern) == 0 {
+				return true
+			}
+			for i := range name {
+				if matchSegments(pattern, name[i:]) {
+					return true
+				}
+			}
+			return false
+		}
+
+		if len(name) == 0 {
+			return false
+		}
+		if ok, err := path.Match(pattern[0], name[0]); err != nil || !ok {
+			return false
+		}
+		pattern, name = pattern[1:], name[1:]
+	}
+	return len(name) == 0
+}
+
+// matchesPathOrParent reports whether the pattern matches the path or one of
+// its parent directories, so that excluding a directory excludes its files.
+func matchesPathOrParent(pattern, name string) bool {
+	for name != "." && name != "" {
+		if MatchPattern(pattern, name) {
+			return true
+		}
+		name = path.Dir(name)
+	}
+	return false
+}
+
+// staticPrefix returns the leading directories of a pattern that contain no
+// glob characters, which is where matching files can be searched from.
+func staticPrefix(pattern string) string {
+	segments := strings.Split(pattern, "/")
+	var prefix []string
+	for _, segment := range segments[:len(segments)-1] {
+		if hasMeta(segment) {
+			break
+		}
+		prefix = append(prefix, segment)
+	}
+	return strings.Join(prefix, "/")
+}
+
+// canMatchBelow reports whether files below the slash-separated directory
+// can match the pattern. The directory's segments must match the leading
+// segments of the pattern and leave at least one segment for the file name,
+// unless a "**" is reached first.
+func canMatchBelow(pattern, dir string) bool {
+	segments := strings.Split(pattern, "/")
+	for i, name := range strings.Split(dir, "/") {
+		if i < len(segments) && segments[i] == "**" {
+			return true
+		}
+		if i >= len(segments)-1 {
+			return false
+		}
+		if ok, err := path.Match(segments[i], name); err != nil || !ok {
+			return false
+		}
+	}
+	return true
+}
+
+// fileTree lists the files below a root and remembers every directory it
+// reads, so that the entries resolved from the same root walk it only once.
+type fileTree struct {
+	root string
+	dirs map[string][]fs.DirEntry
+}
+
+func newFileTree(root string) *fileTree {
+	return &fileTree{root: root, dirs: make(map[string][]fs.DirEntry)}
+}
+
+// walk returns the slash-separated paths, relative to the root, of the files
+// below dir. Subdirectories are only entered when descend is nil or returns
+// true for them, and the .git directory is never entered.
+func (t *fileTree) walk(dir string, descend func(dir string) bool) ([]string, error) {
+	entries, ok := t.dirs[dir]
+	if !ok {
+		var err error
+		entries, err = os.ReadDir(filepath.Join(t.root, filepath.FromSlash(dir)))
+		if err != nil {
+			return nil, err
+		}
+		t.dirs[dir] = entries
+	}
+
+	var files []string
+	for _, entry := range entries {
+		rel := path.Join(dir, entry.Name())
+		if !entry.IsDir() {
+			files = append(files, rel)
+			continue
+		}
+		if entry.Name() == ".git" || (descend != nil && !descend(rel)) {
+			continue
+		}
+
+		nested, err := t.walk(rel, descend)
+		if err != nil {
+			return nil, err
+		}
+		files = append(files, nested...)
+	}
+	return files, nil
+}
+
+// walkFiles returns the slash-separated paths, relative to root, of all files
+// below dir. The .git directory is never entered.
+func walkFiles(root, dir string) ([]string, error) {
+	return newFileTree(root).walk(dir, nil)
+}
@@ -0,0 +1,145 @@
+package copy
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"testing"
+)
+
+func TestMatchPattern(t *testing.T) {
+	tests := []struct {
+		pattern string
+		name    string
+		want    bool
+	}{
+		{".env*", ".env", true},
+		{".env*", ".env.local", true},
+		{".env*", "sub/.env", false},
+		{"**/.env", "sub/.env", true},
+		{"**/.env", ".env", true},
+		{"config/**/*.local.yml", "config/app.local.yml", true},
+		{"config/**/*.local.yml", "config/dev/db/app.local.yml", true},
+		{"config/**/*.local.yml", "config/app.yml", false},
+		{"config/**/*.local.yml", "other/app.local.yml", false},
+		{"config/**", "config/a/b.txt", true},
+		{"config/*", "config/a/b.txt", false},
+		{"[invalid", "invalid", false},
+	}
+
+	for _, tt := range tests {
+		if got := MatchPattern(tt.pattern, tt.name); got != tt.want {
+			t.Errorf("MatchPattern(%q, %q) = %v, want %v", tt.pattern, tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestMatchesPathOrParent(t *testing.T) {
+	if !matchesPathOrParent("fixtures/large", "fixtures/large/db.sqlite") {
+		t.Error("matchesPathOrParent() should match files below an excluded directory")
+	}
+	if matchesPathOrParent("fixtures/large", "fixtures/small/db.sqlite") {
+		t.Error("matchesPathOrParent() should not match unrelated files")
+	}
+}
+
+func TestStaticPrefix(t *testing.T) {
+	tests := map[string]string{
+		"config/**/*.local.yml": "config",
+		"a/b/*.txt":             "a/b",
+		".env*":                 "",
+		"**/.env":               "",
+	}
+
+	for pattern, want := range tests {
+		if got := staticPrefix(pattern); got != want {
+			t.Errorf("staticPrefix(%q) = %q, want %q", pattern, got, want)
+		}
+	}
+}
+
+func TestWalkFiles(t *testing.T) {
+	root := t.TempDir()
+	for _, file := range []string{"a.txt", "dir/b.txt", "dir/nested/c.txt", ".git/config"} {
+		full := filepath.Join(root, filepath.FromSlash(file))
+		if err := os.MkdirAll(filepath.Dir(full), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(full, []byte(file), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	files, err := walkFiles(root, "")
+	if err != nil {
+		t.Fatalf("walkFiles() error = %v", err)
+	}
+	sort.Strings(files)
+
+	want := []string{"a.txt", "dir/b.txt", "dir/nested/c.txt"}
+	if !reflect.DeepEqual(files, want) {
+		t.Errorf("walkFiles() = %v, want %v", files, want)
+	}
+}
+
+func TestCanMatchBelow(t *testing.T) {
+	tests := []struct {
+		pattern string
+		dir     string
+		want    bool
+	}{
+		{".env*", "node_modules", false},
+		{"*.local", "build", false},
+		{"config/*.yml", "config", true},
+		{"config/*.yml", "config/nested", false},
+		{"apps/*/.env", "apps/web", true},
+		{"apps/*/.env", "packages/web", false},
+		{"**/.env", "node_modules/pkg", true},
+		{"config/**/*.yml", "config/a/b", true},
+		{"config/**/*.yml", "docs", false},
+	}
+
+	for _, tt := range tests {
+		if got := canMatchBelow(tt.pattern, tt.dir); got != tt.want {
+			t.Errorf("canMatchBelow(%q, %q) = %v, want %v", tt.pattern, tt.dir, got, tt.want)
+		}
+	}
+}
+
+func TestResolveEntrySkipsUnmatchableDirectories(t *testing.T) {
+	root := t.TempDir()
+	writeTree(t, root, map[string]string{
+		".env":                       "A=1",
+		".env.local":                 "B=2",
+		"node_modules/pkg/.env":      "C=3",
+		"apps/web/.env":              "D=4",
+		"apps/web/node_modules/x.js": "x",
+	})
+
+	tree := newFileTree(root)
+	files, err := resolveEntry(tree, ".env*")
+	if err != nil {
+		t.Fatalf("resolveEntry() error = %v", err)
+	}
+	if want := []string{".env", ".env.local"}; !reflect.DeepEqual(files, want) {
+		t.Errorf("resolveEntry() = %v, want %v", files, want)
+	}
+
+	files, err = resolveEntry(tree, "apps/*/.env")
+	if err != nil {
+		t.Fatalf("resolveEntry() error = %v", err)
+	}
+	if want := []string{"apps/web/.env"}; !reflect.DeepEqual(files, want) {
+		t.Errorf("resolveEntry() = %v, want %v", files, want)
+	}
+
+	for _, dir := range []string{"node_modules", "apps/web/node_modules"} {
+		if _, ok := tree.dirs[dir]; ok {
+			t.Errorf("resolveEntry() read %s, which the patterns cannot match", dir)
+		}
+	}
+	if _, ok := tree.dirs[""]; !ok {
+		t.Error("resolveEntry() should keep the listing of the root for later entries")
+	}
+}
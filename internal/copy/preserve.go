@@ -0,0 +1,120 @@
+package copy
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/daisuke310vvv/sproutee/internal/config"
+)
+
+// copier places files in a worktree and carries the metadata options. It
+// remembers the directories it created so their modes can be applied once
+// every file has been written.
+type copier struct {
+	preserve config.PreserveConfig
+	verify   bool
+
+	mu       sync.Mutex
+	dirModes map[string]os.FileMode
+}
+
+func newCopier(cfg *config.Config) *copier {
+	c := &copier{verify: cfg.VerifyCopies, dirModes: make(map[string]os.FileMode)}
+	if cfg.Preserve != nil {
+		c.preserve = *cfg.Preserve
+	}
+	return c
+}
+
+// mkdirParents creates the missing parent directories of dst. With
+// dir_modes, each created directory is recorded with the mode of the source
+// directory at the same position, as long as their names match.
+func (c *copier) mkdirParents(src, dst string) error {
+	dstDir := filepath.Dir(dst)
+	if !c.preserve.DirModes {
+		if err := os.MkdirAll(dstDir, 0o755); err != nil {
+			return fmt.Errorf("failed to create target directory: %w", err)
+		}
+		return nil
+	}
+
+	var missing, sources []string
+	srcDir := filepath.Dir(src)
+	for dir := dstDir; ; dir, srcDir = filepath.Dir(dir), filepath.Dir(srcDir) {
+		if _, err := os.Stat(dir); err == nil || filepath.Dir(dir) == dir {
+			break
+		}
+		missing = append(missing, dir)
+		sources = append(sources, srcDir)
+	}
+
+	if err := os.MkdirAll(dstDir, 0o755); err != nil {
+		return fmt.Errorf("failed to create target directory: %w", err)
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for i, dir := range missing {
+		if filepath.Base(dir) != filepath.Base(sources[i]) {
+			break
+		}
+		info, err := os.Stat(sources[i])
+		if err != nil || !info.IsDir() {
+			break
+		}
+		c.dirModes[dir] = info.Mode().Perm()
+	}
+	return nil
+}
+
+// finish applies the recorded directory modes. It runs after all files are
+// written because a read-only directory could not receive them.
+func (c *copier) finish() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for dir, mode := range c.dirModes {
+		if err := os.Chmod(dir, mode); err != nil {
+			return fmt.Errorf("failed to set directory mode: %w", err)
+		}
+	}
+	c.dirModes = make(map[string]os.FileMode)
+	return nil
+}
+
+// copyMetadata carries modification and access times (as recorded in
+// srcInfo) and extended attributes over from src to dst as configured.
+func (c *copier) copyMetadata(src, dst string, srcInfo os.FileInfo) error {
+	if c.preserve.Xattrs {
+		if err := copyXattrs(src, dst); err != nil {
+			return fmt.Errorf("failed to copy extended attributes: %w", err)
+		}
+	}
+
+	if c.preserve.Times {
+		if err := os.Chtimes(dst, accessTime(srcInfo), srcInfo.ModTime()); err != nil {
+			return fmt.Errorf("failed to set file times: %w", err)
+		}
+	}
+	return nil
+}
+
+// copySymlink recreates the symlink src at dst with the same, possibly
+// relative, target.
+func copySymlink(src, dst string) error {
+	target, err := os.Readlink(src)
+	if err != nil {
+		return fmt.Errorf("failed to read symlink: %w", err)
+	}
+
+	tempPath, err := reserveTempPath(dst)
+	if err != nil {
+		return err
+	}
+	if err := os.Symlink(target, tempPath); err != nil {
+		return fmt.Errorf("failed to create symlink: %w", err)
+	}
+	return replaceFile(tempPath, dst)
+}
@@ -0,0 +1,139 @@
+package copy
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/daisuke310vvv/sproutee/internal/config"
+)
+
+func TestFilesFromConfigPreserveSymlinks(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("symlinks need privileges on Windows")
+	}
+
+	tempDir := t.TempDir()
+	srcRoot := filepath.Join(tempDir, "src")
+	targetRoot := filepath.Join(tempDir, "target")
+	writeTree(t, srcRoot, map[string]string{"config/.env.dev": "DEV=1"})
+
+	absTarget := filepath.Join(srcRoot, "config", ".env.dev")
+	if err := os.Symlink("config/.env.dev", filepath.Join(srcRoot, ".env")); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink(absTarget, filepath.Join(srcRoot, ".env.abs")); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg := &config.Config{
+		CopyFiles: config.CopyEntries(".env", ".env.abs"),
+		Preserve:  &config.PreserveConfig{Symlinks: true},
+	}
+	report := FilesFromConfig(srcRoot, targetRoot, cfg)
+	if report.FailureCount != 0 {
+		t.Fatalf("FailureCount = %d, want 0: %+v", report.FailureCount, report.Results)
+	}
+
+	for name, want := range map[string]string{".env": "config/.env.dev", ".env.abs": absTarget} {
+		got, err := os.Readlink(filepath.Join(targetRoot, name))
+		if err != nil {
+			t.Errorf("%s should be a symlink: %v", name, err)
+			continue
+		}
+		if got != want {
+			t.Errorf("%s links to %s, want %s", name, got, want)
+		}
+	}
+
+	// Without the option the link is followed and its content copied
+	report = FilesFromConfig(srcRoot, filepath.Join(tempDir, "plain"), &config.Config{CopyFiles: config.CopyEntries(".env")})
+	info, err := os.Lstat(filepath.Join(tempDir, "plain", ".env"))
+	if report.FailureCount != 0 || err != nil || info.Mode()&os.ModeSymlink != 0 {
+		t.Errorf("plain copy of a symlink should be a regular file: %v, %v", report.Results, err)
+	}
+}
+
+func TestFilesFromConfigPreserveTimes(t *testing.T) {
+	tempDir := t.TempDir()
+	srcRoot := filepath.Join(tempDir, "src")
+	targetRoot := filepath.Join(tempDir, "target")
+	writeTree(t, srcRoot, map[string]string{"data.db": "data"})
+
+	atime := time.Date(2023, 1, 2, 3, 4, 5, 0, time.UTC)
+	mtime := time.Date(2022, 6, 7, 8, 9, 10, 0, time.UTC)
+	if err := os.Chtimes(filepath.Join(srcRoot, "data.db"), atime, mtime); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg := &config.Config{
+		CopyFiles: config.CopyEntries("data.db"),
+		Preserve:  &config.PreserveConfig{Times: true},
+	}
+	if report := FilesFromConfig(srcRoot, targetRoot, cfg); report.FailureCount != 0 {
+		t.Fatalf("FailureCount = %d, want 0: %+v", report.FailureCount, report.Results)
+	}
+
+	info, err := os.Stat(filepath.Join(targetRoot, "data.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !info.ModTime().Equal(mtime) {
+		t.Errorf("ModTime = %v, want %v", info.ModTime(), mtime)
+	}
+	if runtime.GOOS == "linux" || runtime.GOOS == "darwin" {
+		if got := accessTime(info); !got.Equal(atime) {
+			t.Errorf("access time = %v, want %v", got, atime)
+		}
+	}
+}
+
+func TestFilesFromConfigPreserveDirModes(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("directory modes are not supported on Windows")
+	}
+
+	tempDir := t.TempDir()
+	srcRoot := filepath.Join(tempDir, "src")
+	targetRoot := filepath.Join(tempDir, "target")
+	writeTree(t, srcRoot, map[string]string{"secrets/keys/app.key": "key", "secrets/keys/other.key": "other"})
+	if err := os.Chmod(filepath.Join(srcRoot, "secrets"), 0o700); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chmod(filepath.Join(srcRoot, "secrets", "keys"), 0o500); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		_ = os.Chmod(filepath.Join(srcRoot, "secrets", "keys"), 0o755)
+		_ = os.Chmod(filepath.Join(targetRoot, "secrets", "keys"), 0o755)
+	})
+
+	cfg := &config.Config{
+		CopyFiles: config.CopyEntries("secrets"),
+		Preserve:  &config.PreserveConfig{DirModes: true},
+	}
+	if report := FilesFromConfig(srcRoot, targetRoot, cfg); report.FailureCount != 0 {
+		t.Fatalf("FailureCount = %d, want 0: %+v", report.FailureCount, report.Results)
+	}
+
+	for dir, want := range map[string]os.FileMode{"secrets": 0o700, "secrets/keys": 0o500} {
+		info, err := os.Stat(filepath.Join(targetRoot, filepath.FromSlash(dir)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := info.Mode().Perm(); got != want {
+			t.Errorf("mode of %s = %o, want %o", dir, got, want)
+		}
+	}
+
+	// The worktree root already existed and keeps its mode
+	info, err := os.Stat(targetRoot)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Mode().Perm() == 0o500 || info.Mode().Perm() == 0o700 {
+		t.Errorf("existing directory mode should not change, got %o", info.Mode().Perm())
+	}
+}
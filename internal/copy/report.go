@@ -0,0 +1,353 @@
+package copy
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/daisuke310vvv/sproutee/internal/config"
+)
+
+// States of a result as rendered in reports.
+const (
+	StateSkipped = "skipped"
+	StateFailed  = "failed"
+)
+
+// Report collects the results of copying files to a worktree.
+type Report struct {
+	SourceRoot string
+	TargetRoot string
+	Results    []Result
+
+	TotalFiles     int
+	SuccessCount   int
+	UnchangedCount int
+	VerifiedCount  int
+	SkippedCount   int
+	FailureCount   int
+
+	// TotalBytes is the size of the files written, Duration the time the
+	// whole copy took.
+	TotalBytes int64
+	Duration   time.Duration
+}
+
+func (r *Report) AddResult(result Result) {
+	r.Results = append(r.Results, result)
+	r.TotalFiles++
+	switch {
+	case result.Success && result.Skipped:
+		r.SkippedCount++
+	case result.Success && result.Status == StatusUnchanged:
+		r.UnchangedCount++
+	case result.Success:
+		r.SuccessCount++
+		r.TotalBytes += result.Bytes
+		if result.Status == StatusVerified {
+			r.VerifiedCount++
+		}
+	default:
+		r.FailureCount++
+	}
+}
+
+// State returns the status of a result that was written or left unchanged,
+// and StateSkipped or StateFailed otherwise.
+func (r Result) State() string {
+	switch {
+	case !r.Success:
+		return StateFailed
+	case r.Skipped:
+		return StateSkipped
+	case r.Status == "":
+		return StatusCopied
+	default:
+		return r.Status
+	}
+}
+
+// displayPath returns the slash-separated path of the target in the
+// worktree, falling back to the full target path.
+func (r Result) displayPath() string {
+	if r.RelativePath != "" {
+		return r.RelativePath
+	}
+	return r.TargetPath
+}
+
+// sourcePath returns the slash-separated path of a result's source relative
+// to the source root, or the full path for sources outside it.
+func (r *Report) sourcePath(result Result) string {
+	if r.SourceRoot != "" {
+		if rel, err := filepath.Rel(r.SourceRoot, result.SourcePath); err == nil && !config.EscapesRoot(rel) {
+			return filepath.ToSlash(rel)
+		}
+	}
+	return filepath.ToSlash(result.SourcePath)
+}
+
+// RequiredFailures returns the failed results of required entries.
+func (r *Report) RequiredFailures() []Result {
+	var failures []Result
+	for _, result := range r.Results {
+		if result.Required && !result.Success {
+			failures = append(failures, result)
+		}
+	}
+	return failures
+}
+
+// PrintSummary writes the plain-text summary to standard output.
+func (r *Report) PrintSummary() {
+	_ = r.WriteText(os.Stdout)
+}
+
+// WriteText writes the plain-text summary of the report to w.
+func (r *Report) WriteText(w io.Writer) error {
+	var b strings.Builder
+	if r.TotalFiles == 0 {
+		b.WriteString("📁 No files configured for copying.\n")
+		_, err := io.WriteString(w, b.String())
+		return err
+	}
+
+	fmt.Fprintf(&b, "📁 File Copy Summary:\n")
+	fmt.Fprintf(&b, "   Total files: %d", r.TotalFiles)
+	if r.Duration > 0 {
+		fmt.Fprintf(&b, " (%s written in %s)", FormatBytes(r.TotalBytes), r.Duration.Round(time.Millisecond))
+	}
+	b.WriteString("\n")
+	fmt.Fprintf(&b, "   ✅ Successful: %d\n", r.SuccessCount)
+	if r.VerifiedCount > 0 {
+		fmt.Fprintf(&b, "   🔒 Verified: %d\n", r.VerifiedCount)
+	}
+	if r.UnchangedCount > 0 {
+		fmt.Fprintf(&b, "   💤 Unchanged: %d\n", r.UnchangedCount)
+	}
+	if r.SkippedCount > 0 {
+		fmt.Fprintf(&b, "   ⏭️  Skipped: %d\n", r.SkippedCount)
+	}
+
+	if r.FailureCount > 0 {
+		fmt.Fprintf(&b, "   ❌ Failed: %d\n", r.FailureCount)
+		b.WriteString("\n📋 Failed copies:\n")
+		for _, result := range r.Results {
+			if !result.Success {
+				required := ""
+				if result.Required {
+					required = " (required)"
+				}
+				fmt.Fprintf(&b, "   • %s → %s%s\n", r.sourcePath(result), result.displayPath(), required)
+				fmt.Fprintf(&b, "     Error: %v\n", result.Error)
+			}
+		}
+	}
+
+	if r.SuccessCount > 0 {
+		r.writeCopied(&b, OriginCopyFiles, "📋 Successfully copied files:")
+		r.writeCopied(&b, OriginCopyIgnored, "📋 Git-ignored files found and copied:")
+		r.writeCopied(&b, OriginOverlay, "📋 Overlay files copied:")
+		r.writeCopied(&b, OriginVault, "🔐 Files decrypted from the vault:")
+	}
+
+	r.writeConflicts(&b)
+	r.writeOptionalMissing(&b)
+
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+func (r *Report) writeOptionalMissing(b *strings.Builder) {
+	var missing []string
+	for _, result := range r.Results {
+		if result.SkipReason == SkipReasonOptionalMissing {
+			missing = append(missing, result.Pattern)
+		}
+	}
+	if len(missing) > 0 {
+		fmt.Fprintf(b, "\nℹ️  Optional files not found: %s\n", strings.Join(missing, ", "))
+	}
+}
+
+func (r *Report) writeConflicts(b *strings.Builder) {
+	printed := false
+	for _, result := range r.Results {
+		conflict := result.SkipReason == SkipReasonTargetExists
+		if !conflict && !result.OverwroteTracked && result.BackupPath == "" {
+			continue
+		}
+		if !printed {
+			b.WriteString("\n⚠️  Existing files:\n")
+			printed = true
+		}
+
+		if conflict {
+			fmt.Fprintf(b, "   • %s (kept, not copied)\n", result.displayPath())
+			continue
+		}
+
+		var details []string
+		if result.OverwroteTracked {
+			details = append(details, "overwrote tracked file")
+		}
+		if result.BackupPath != "" {
+			details = append(details, "backed up to "+filepath.Base(result.BackupPath))
+		}
+		fmt.Fprintf(b, "   • %s (%s)\n", result.displayPath(), strings.Join(details, ", "))
+	}
+}
+
+func (r *Report) writeCopied(b *strings.Builder, origin, title string) {
+	printed := false
+	for _, result := range r.Results {
+		resultOrigin := result.Origin
+		if resultOrigin == "" {
+			resultOrigin = OriginCopyFiles
+		}
+		if result.State() != StatusCopied && result.State() != StatusVerified || resultOrigin != origin {
+			continue
+		}
+		if !printed {
+			b.WriteString("\n" + title + "\n")
+			printed = true
+		}
+
+		var details []string
+		if result.Pattern != "" && result.RelativePath != path.Clean(filepath.ToSlash(result.Pattern)) {
+			details = append(details, "from "+result.Pattern)
+		}
+		if result.Mode != "" && result.Mode != config.CopyModeCopy {
+			details = append(details, result.Mode)
+		}
+		if result.Template {
+			details = append(details, "rendered")
+		}
+		if len(result.EnvKeys) > 0 {
+			details = append(details, "set "+strings.Join(result.EnvKeys, ", "))
+		}
+
+		if len(details) > 0 {
+			fmt.Fprintf(b, "   • %s (%s)\n", result.displayPath(), strings.Join(details, ", "))
+		} else {
+			fmt.Fprintf(b, "   • %s\n", result.displayPath())
+		}
+	}
+}
+
+// jsonReport is the JSON form of a Report. Paths in results are relative to
+// the roots and use forward slashes on every platform.
+type jsonReport struct {
+	SourceRoot string       `json:"source_root"`
+	TargetRoot string       `json:"target_root"`
+	Summary    jsonSummary  `json:"summary"`
+	Results    []jsonResult `json:"results"`
+}
+
+type jsonSummary struct {
+	Total      int   `json:"total"`
+	Copied     int   `json:"copied"`
+	Unchanged  int   `json:"unchanged"`
+	Verified   int   `json:"verified"`
+	Skipped    int   `json:"skipped"`
+	Failed     int   `json:"failed"`
+	Bytes      int64 `json:"bytes"`
+	DurationMS int64 `json:"duration_ms"`
+}
+
+type jsonResult struct {
+	Path             string   `json:"path"`
+	Source           string   `json:"source"`
+	Pattern          string   `json:"pattern,omitempty"`
+	Origin           string   `json:"origin"`
+	Mode             string   `json:"mode,omitempty"`
+	State            string   `json:"state"`
+	SkipReason       string   `json:"skip_reason,omitempty"`
+	Error            string   `json:"error,omitempty"`
+	Bytes            int64    `json:"bytes"`
+	DurationMS       int64    `json:"duration_ms"`
+	Required         bool     `json:"required,omitempty"`
+	Template         bool     `json:"template,omitempty"`
+	EnvKeys          []string `json:"env_keys,omitempty"`
+	BackupPath       string   `json:"backup_path,omitempty"`
+	OverwroteTracked bool     `json:"overwrote_tracked,omitempty"`
+}
+
+func (r *Report) toJSON() jsonReport {
+	report := jsonReport{
+		SourceRoot: r.SourceRoot,
+		TargetRoot: r.TargetRoot,
+		Summary: jsonSummary{
+			Total:      r.TotalFiles,
+			Copied:     r.SuccessCount,
+			Unchanged:  r.UnchangedCount,
+			Verified:   r.VerifiedCount,
+			Skipped:    r.SkippedCount,
+			Failed:     r.FailureCount,
+			Bytes:      r.TotalBytes,
+			DurationMS: r.Duration.Milliseconds(),
+		},
+		Results: make([]jsonResult, 0, len(r.Results)),
+	}
+
+	for _, result := range r.Results {
+		origin := result.Origin
+		if origin == "" {
+			origin = OriginCopyFiles
+		}
+
+		converted := jsonResult{
+			Path:             result.displayPath(),
+			Source:           r.sourcePath(result),
+			Pattern:          result.Pattern,
+			Origin:           origin,
+			Mode:             result.Mode,
+			State:            result.State(),
+			SkipReason:       result.SkipReason,
+			Bytes:            result.Bytes,
+			DurationMS:       result.Duration.Milliseconds(),
+			Required:         result.Required,
+			Template:         result.Template,
+			EnvKeys:          result.EnvKeys,
+			BackupPath:       result.BackupPath,
+			OverwroteTracked: result.OverwroteTracked,
+		}
+		if result.Error != nil {
+			converted.Error = result.Error.Error()
+		}
+		report.Results = append(report.Results, converted)
+	}
+	return report
+}
+
+// MarshalJSON encodes the report in the form written by WriteJSON.
+func (r *Report) MarshalJSON() ([]byte, error) {
+	return json.Marshal(r.toJSON())
+}
+
+// WriteJSON writes the report to w as indented JSON.
+func (r *Report) WriteJSON(w io.Writer) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(r.toJSON())
+}
+
+// FormatBytes formats a byte count with a binary unit, e.g. "1.5 MB".
+func FormatBytes(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+
+	div, exp := int64(unit), 0
+	for m := n / unit; m >= unit; m /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %cB", float64(n)/float64(div), "KMGTPE"[exp])
+}
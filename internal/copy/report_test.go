@@ -0,0 +1,101 @@
+package copy
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/daisuke310vvv/sproutee/internal/config"
+)
+
+func TestReportWriteText(t *testing.T) {
+	tempDir := t.TempDir()
+	srcRoot := filepath.Join(tempDir, "src")
+	targetRoot := filepath.Join(tempDir, "target")
+	writeTree(t, srcRoot, map[string]string{
+		"a/.env": "A=1",
+		"b/.env": "B=1",
+	})
+
+	cfg := &config.Config{CopyFiles: config.CopyEntries("a/.env", "b/.env", "missing.txt")}
+	report := FilesFromConfig(srcRoot, targetRoot, cfg)
+
+	var out bytes.Buffer
+	if err := report.WriteText(&out); err != nil {
+		t.Fatalf("WriteText() error = %v", err)
+	}
+	text := out.String()
+	for _, want := range []string{"• a/.env\n", "• b/.env\n", "Total files: 3", "❌ Failed: 1", "• missing.txt → missing.txt\n"} {
+		if !strings.Contains(text, want) {
+			t.Errorf("WriteText() output lacks %q:\n%s", want, text)
+		}
+	}
+}
+
+func TestReportWriteJSON(t *testing.T) {
+	report := &Report{SourceRoot: filepath.FromSlash("/repo"), TargetRoot: filepath.FromSlash("/wt")}
+	report.AddResult(Result{
+		SourcePath:   filepath.FromSlash("/repo/config/.env"),
+		TargetPath:   filepath.FromSlash("/wt/config/.env"),
+		RelativePath: "config/.env",
+		Pattern:      "config/.env",
+		Mode:         config.CopyModeCopy,
+		Success:      true,
+		Status:       StatusVerified,
+		Bytes:        42,
+		Duration:     3 * time.Millisecond,
+	})
+	report.AddResult(Result{RelativePath: "Makefile", Success: true, Skipped: true, SkipReason: SkipReasonTargetExists})
+	report.AddResult(Result{RelativePath: "secret.key", Error: errors.New("permission denied")})
+
+	var out bytes.Buffer
+	if err := report.WriteJSON(&out); err != nil {
+		t.Fatalf("WriteJSON() error = %v", err)
+	}
+
+	var decoded jsonReport
+	if err := json.Unmarshal(out.Bytes(), &decoded); err != nil {
+		t.Fatalf("WriteJSON() wrote invalid JSON: %v\n%s", err, out.String())
+	}
+
+	wantSummary := jsonSummary{Total: 3, Copied: 1, Verified: 1, Skipped: 1, Failed: 1, Bytes: 42}
+	if decoded.Summary != wantSummary {
+		t.Errorf("summary = %+v, want %+v", decoded.Summary, wantSummary)
+	}
+	first := decoded.Results[0]
+	if first.Path != "config/.env" || first.Source != "config/.env" || first.State != StatusVerified || first.Bytes != 42 || first.DurationMS != 3 {
+		t.Errorf("results[0] = %+v", first)
+	}
+	if decoded.Results[1].State != StateSkipped || decoded.Results[1].SkipReason != SkipReasonTargetExists {
+		t.Errorf("results[1] = %+v", decoded.Results[1])
+	}
+	if decoded.Results[2].State != StateFailed || decoded.Results[2].Error != "permission denied" {
+		t.Errorf("results[2] = %+v", decoded.Results[2])
+	}
+
+	// Reports marshal to the same form
+	data, err := json.Marshal(report)
+	if err != nil {
+		t.Fatalf("Marshal() error = %v", err)
+	}
+	var compact bytes.Buffer
+	if err := json.Compact(&compact, out.Bytes()); err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != compact.String() {
+		t.Errorf("Marshal() = %s, want %s", data, compact.String())
+	}
+}
+
+func TestFormatBytes(t *testing.T) {
+	tests := map[int64]string{0: "0 B", 1023: "1023 B", 1536: "1.5 KB", 5 << 20: "5.0 MB"}
+	for n, want := range tests {
+		if got := FormatBytes(n); got != want {
+			t.Errorf("FormatBytes(%d) = %s, want %s", n, got, want)
+		}
+	}
+}
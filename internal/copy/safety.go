@@ -0,0 +1,81 @@
+package copy
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/daisuke310vvv/sproutee/internal/config"
+)
+
+// resolvePath resolves the symlinks of the existing part of p. Trailing
+// components that do not exist yet are appended unchanged.
+func resolvePath(p string) (string, error) {
+	p, err := filepath.Abs(p)
+	if err != nil {
+		return "", err
+	}
+
+	var missing []string
+	existing := p
+	for {
+		if _, err := os.Lstat(existing); err == nil {
+			break
+		}
+		parent := filepath.Dir(existing)
+		if parent == existing {
+			break
+		}
+		missing = append([]string{filepath.Base(existing)}, missing...)
+		existing = parent
+	}
+
+	resolved, err := filepath.EvalSymlinks(existing)
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(append([]string{resolved}, missing...)...), nil
+}
+
+func isWithin(p, dir string) bool {
+	return p == dir || strings.HasPrefix(p, dir+string(filepath.Separator))
+}
+
+// checkSource makes sure a source file, once its symlinks are resolved, lies
+// in the repository or in an allowed external source, so a committed symlink
+// cannot pull in arbitrary files.
+func checkSource(srcRoot string, cfg *config.Config, source string) error {
+	resolved, err := filepath.EvalSymlinks(source)
+	if err != nil {
+		// Missing sources are reported when they are copied
+		return nil
+	}
+
+	root, err := resolvePath(srcRoot)
+	if err != nil {
+		return fmt.Errorf("failed to resolve repository root: %w", err)
+	}
+	if isWithin(resolved, root) || cfg.ExternalSourceAllowed(resolved) {
+		return nil
+	}
+	return fmt.Errorf("source resolves outside the repository and is not listed in allowed_external_sources: %s", source)
+}
+
+// checkTarget makes sure a target is written inside the worktree, even when
+// one of its parent directories is a symlink.
+func checkTarget(targetRoot, target string) error {
+	root, err := resolvePath(targetRoot)
+	if err != nil {
+		return fmt.Errorf("failed to resolve worktree root: %w", err)
+	}
+	dir, err := resolvePath(filepath.Dir(target))
+	if err != nil {
+		return fmt.Errorf("failed to resolve target directory: %w", err)
+	}
+
+	if !isWithin(dir, root) {
+		return fmt.Errorf("target resolves outside the worktree: %s", target)
+	}
+	return nil
+}
@@ -0,0 +1,60 @@
+package copy
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"github.com/daisuke310vvv/sproutee/internal/config"
+)
+
+func TestFilesFromConfigRejectsEscapingSymlinks(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("symlinks need privileges on Windows")
+	}
+
+	tempDir := t.TempDir()
+	srcRoot := filepath.Join(tempDir, "src")
+	targetRoot := filepath.Join(tempDir, "target")
+	outside := filepath.Join(tempDir, "outside")
+	writeTree(t, srcRoot, map[string]string{"config/app.yml": "app", "inside.txt": "inside"})
+	writeTree(t, outside, map[string]string{"id_rsa": "private"})
+	if err := os.MkdirAll(targetRoot, 0o755); err != nil {
+		t.Fatal(err)
+	}
+
+	// A committed symlink pointing outside the repository
+	if err := os.Symlink(filepath.Join(outside, "id_rsa"), filepath.Join(srcRoot, ".env")); err != nil {
+		t.Fatal(err)
+	}
+	// A symlink inside the repository is fine
+	if err := os.Symlink("inside.txt", filepath.Join(srcRoot, "link.txt")); err != nil {
+		t.Fatal(err)
+	}
+	// A directory in the worktree that points outside of it
+	if err := os.Symlink(outside, filepath.Join(targetRoot, "config")); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg := &config.Config{CopyFiles: config.CopyEntries(".env", "link.txt", "config/app.yml")}
+	report := FilesFromConfig(srcRoot, targetRoot, cfg)
+
+	want := map[string]bool{".env": false, "link.txt": true, "app.yml": false}
+	for _, result := range report.Results {
+		if ok := want[filepath.Base(result.TargetPath)]; result.Success != ok {
+			t.Errorf("Success for %s = %v, want %v (error: %v)", result.TargetPath, result.Success, ok, result.Error)
+		}
+	}
+	if FileExists(filepath.Join(outside, "app.yml")) {
+		t.Error("a file was written through a symlink outside the worktree")
+	}
+
+	report = FilesFromConfig(srcRoot, filepath.Join(tempDir, "other"), &config.Config{
+		CopyFiles:              config.CopyEntries(".env"),
+		AllowedExternalSources: []string{outside},
+	})
+	if report.SuccessCount != 1 {
+		t.Errorf("a symlink into an allowed external source should be copied: %+v", report.Results)
+	}
+}
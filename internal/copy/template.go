@@ -0,0 +1,153 @@
+package copy
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/template"
+)
+
+// TemplateContext is the data that copy entries marked as templates are
+// rendered with, e.g. {{.Name}}, {{.Ports.web}} or {{.Env.USER}}.
+type TemplateContext struct {
+	Name   string
+	Branch string
+	Path   string
+	Index  int
+	Ports  map[string]int
+	Env    map[string]string
+}
+
+// NewTemplateContext builds the context of a worktree. Each configured base
+// port is offset by the worktree index so that worktrees do not collide.
+func NewTemplateContext(name, branch, path string, index int, basePorts map[string]int) *TemplateContext {
+	ctx := &TemplateContext{
+		Name:   name,
+		Branch: branch,
+		Path:   path,
+		Index:  index,
+		Ports:  make(map[string]int, len(basePorts)),
+		Env:    make(map[string]string),
+	}
+	for name, port := range basePorts {
+		ctx.Ports[name] = port + index
+	}
+	for _, entry := range os.Environ() {
+		if key, value, ok := strings.Cut(entry, "="); ok {
+			ctx.Env[key] = value
+		}
+	}
+	return ctx
+}
+
+// defaultTemplateContext is used when the caller did not describe the
+// worktree: only the path and name are known.
+func defaultTemplateContext(targetRoot string) *TemplateContext {
+	return NewTemplateContext(filepath.Base(targetRoot), "", targetRoot, 0, nil)
+}
+
+// renderTemplate renders the text/template in src with ctx.
+func renderTemplate(src string, ctx *TemplateContext) ([]byte, error) {
+	content, err := os.ReadFile(src)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read template: %w", err)
+	}
+
+	tmpl, err := template.New(filepath.Base(src)).Option("missingkey=error").Parse(string(content))
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse template: %w", err)
+	}
+
+	var rendered bytes.Buffer
+	if err := tmpl.Execute(&rendered, ctx); err != nil {
+		return nil, fmt.Errorf("failed to render template: %w", err)
+	}
+	return rendered.Bytes(), nil
+}
+
+// writeFile writes content to dst atomically with the permissions of src.
+func writeFile(src, dst string, content []byte) error {
+	perm := os.FileMode(0o644)
+	if info, err := os.Stat(src); err == nil {
+		perm = info.Mode().Perm()
+	}
+
+	targetFile, err := os.CreateTemp(filepath.Dir(dst), tempPattern(dst))
+	if err != nil {
+		return fmt.Errorf("failed to create target file: %w", err)
+	}
+	tempPath := targetFile.Name()
+
+	if _, err := targetFile.Write(content); err != nil {
+		_ = targetFile.Close()
+		_ = os.Remove(tempPath)
+		return fmt.Errorf("failed to write target file: %w", err)
+	}
+	_ = targetFile.Chmod(perm)
+	if err := targetFile.Close(); err != nil {
+		_ = os.Remove(tempPath)
+		return fmt.Errorf("failed to write target file: %w", err)
+	}
+
+	return replaceFile(tempPath, dst)
+}
+
+// rendered reports whether the content written for a planned copy is
+// produced by sproutee rather than copied from its source.
+func (p plannedCopy) rendered() bool {
+	return p.template || len(p.env) > 0 || p.secret != nil
+}
+
+// plannedContent returns what a rendered entry writes for src: the
+// decrypted vault file, or the rendered template with the env overrides
+// applied.
+func plannedContent(src string, planned plannedCopy, ctx *TemplateContext) ([]byte, error) {
+	if planned.secret != nil {
+		return planned.secret.Decrypt(planned.relativePath)
+	}
+
+	var content []byte
+	var err error
+	if planned.template {
+		content, err = renderTemplate(src, ctx)
+	} else if content, err = os.ReadFile(src); err != nil {
+		err = fmt.Errorf("failed to read source file: %w", err)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if len(planned.env) > 0 {
+		return applyEnv(content, planned.env, ctx)
+	}
+	return content, nil
+}
+
+// renderedContent returns the content of a rendered planned copy, and nil
+// for copies that are placed from their source.
+func renderedContent(planned plannedCopy, ctx *TemplateContext) ([]byte, error) {
+	if !planned.rendered() {
+		return nil, nil
+	}
+	return plannedContent(planned.sourcePath, planned, ctx)
+}
+
+// renderToFile writes the content rendered for src to dst and returns the
+// status of the result.
+func (c *copier) renderToFile(src, dst string, content []byte) (string, error) {
+	if err := c.mkdirParents(src, dst); err != nil {
+		return "", err
+	}
+	if err := writeFile(src, dst, content); err != nil {
+		return "", err
+	}
+	if !c.verify {
+		return StatusCopied, nil
+	}
+	if err := verifyChecksum(dst, contentChecksum(content)); err != nil {
+		return "", err
+	}
+	return StatusVerified, nil
+}
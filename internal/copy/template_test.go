@@ -0,0 +1,70 @@
+package copy
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/daisuke310vvv/sproutee/internal/config"
+)
+
+func TestNewTemplateContext(t *testing.T) {
+	t.Setenv("SPROUTEE_TEST_VAR", "value")
+
+	ctx := NewTemplateContext("feature", "feature/auth", "/wt/feature", 2, map[string]int{"web": 3000})
+	if ctx.Ports["web"] != 3002 {
+		t.Errorf("Ports[web] = %d, want 3002", ctx.Ports["web"])
+	}
+	if ctx.Env["SPROUTEE_TEST_VAR"] != "value" {
+		t.Errorf("Env[SPROUTEE_TEST_VAR] = %q, want %q", ctx.Env["SPROUTEE_TEST_VAR"], "value")
+	}
+}
+
+func TestFilesFromConfigTemplates(t *testing.T) {
+	tempDir := t.TempDir()
+	srcRoot := filepath.Join(tempDir, "src")
+	targetRoot := filepath.Join(tempDir, "target")
+	writeTree(t, srcRoot, map[string]string{
+		".env.tmpl":   "COMPOSE_PROJECT_NAME=app-{{.Name}}\nDATABASE=app_{{.Index}}\nPORT={{.Ports.web}}\nBRANCH={{.Branch}}\n",
+		"broken.tmpl": "{{.Missing}}",
+		"plain.txt":   "{{.Name}}",
+	})
+
+	cfg := &config.Config{CopyFiles: []config.CopyEntry{
+		{From: ".env.tmpl", To: ".env", Template: true},
+		{From: "broken.tmpl", Template: true},
+		{From: "plain.txt"},
+	}}
+	opts := Options{Template: NewTemplateContext("feature", "feature/auth", targetRoot, 3, map[string]int{"web": 3000})}
+	report := FilesFromConfigWithOptions(srcRoot, targetRoot, cfg, opts)
+
+	if report.SuccessCount != 2 || report.FailureCount != 1 {
+		t.Fatalf("FilesFromConfigWithOptions() = %d successes, %d failures, want 2 and 1: %+v", report.SuccessCount, report.FailureCount, report.Results)
+	}
+	if err := report.Results[1].Error; err == nil || !strings.Contains(err.Error(), "render template") {
+		t.Errorf("template error = %v, want a render error", err)
+	}
+
+	content, err := os.ReadFile(filepath.Join(targetRoot, ".env"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "COMPOSE_PROJECT_NAME=app-feature\nDATABASE=app_3\nPORT=3003\nBRANCH=feature/auth\n"
+	if string(content) != want {
+		t.Errorf("rendered .env = %q, want %q", content, want)
+	}
+
+	plain, err := os.ReadFile(filepath.Join(targetRoot, "plain.txt"))
+	if err != nil || string(plain) != "{{.Name}}" {
+		t.Errorf("plain.txt = %q, %v, want it copied verbatim", plain, err)
+	}
+
+	drifts, err := CheckDrift(srcRoot, targetRoot, &config.Config{CopyFiles: cfg.CopyFiles[:1]}, opts.Template)
+	if err != nil {
+		t.Fatalf("CheckDrift() error = %v", err)
+	}
+	if len(drifts) != 1 || drifts[0].Status != DriftInSync {
+		t.Errorf("CheckDrift() = %+v, want the rendered template in sync", drifts)
+	}
+}
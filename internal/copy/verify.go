@@ -0,0 +1,130 @@
+package copy
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/daisuke310vvv/sproutee/internal/config"
+)
+
+// Statuses of results that were not skipped.
+const (
+	StatusCopied    = "copied"
+	StatusUnchanged = "unchanged"
+	StatusVerified  = "verified"
+)
+
+// sameContent reports whether dst is a regular file with the size,
+// permissions and SHA-256 checksum of src.
+func sameContent(src, dst string) bool {
+	srcInfo, err := os.Stat(src)
+	if err != nil {
+		return false
+	}
+	dstInfo, err := os.Lstat(dst)
+	if err != nil || !dstInfo.Mode().IsRegular() {
+		return false
+	}
+	if srcInfo.Size() != dstInfo.Size() || srcInfo.Mode().Perm() != dstInfo.Mode().Perm() {
+		return false
+	}
+
+	srcSum, err := fileChecksum(src)
+	if err != nil {
+		return false
+	}
+	dstSum, err := fileChecksum(dst)
+	return err == nil && srcSum == dstSum
+}
+
+// contentMatches reports whether dst is a regular file holding content.
+func contentMatches(dst string, content []byte) bool {
+	info, err := os.Lstat(dst)
+	if err != nil || !info.Mode().IsRegular() || info.Size() != int64(len(content)) {
+		return false
+	}
+	existing, err := os.ReadFile(dst)
+	return err == nil && bytes.Equal(existing, content)
+}
+
+// isUnchanged reports whether placing src at dst with mode would leave dst
+// as it is.
+func isUnchanged(src, dst, mode string) bool {
+	switch mode {
+	case config.CopyModeSymlink:
+		absSrc, err := filepath.Abs(src)
+		if err != nil {
+			return false
+		}
+		target, err := os.Readlink(dst)
+		return err == nil && target == absSrc
+	case config.CopyModeHardlink:
+		srcInfo, err := os.Stat(src)
+		if err != nil {
+			return false
+		}
+		dstInfo, err := os.Lstat(dst)
+		return err == nil && os.SameFile(srcInfo, dstInfo)
+	default:
+		return sameContent(src, dst)
+	}
+}
+
+// verifyChecksum compares the SHA-256 checksum of dst with want.
+func verifyChecksum(dst, want string) error {
+	got, err := fileChecksum(dst)
+	if err != nil {
+		return fmt.Errorf("failed to verify target file: %w", err)
+	}
+	if got != want {
+		return fmt.Errorf("verification failed: checksum of %s does not match the source", dst)
+	}
+	return nil
+}
+
+func contentChecksum(content []byte) string {
+	sum := sha256.Sum256(content)
+	return hex.EncodeToString(sum[:])
+}
+
+// unchanged reports whether the target of a planned copy already holds what
+// would be written, so that it can be left alone. content is the rendered
+// content of rendered copies.
+func (c *copier) unchanged(planned plannedCopy, dst, mode string, content []byte) bool {
+	if planned.rendered() {
+		if _, err := os.Lstat(dst); err != nil {
+			return false
+		}
+		return contentMatches(dst, content)
+	}
+	if c.preserve.Symlinks {
+		if info, err := os.Lstat(planned.sourcePath); err != nil || info.Mode()&os.ModeSymlink != 0 {
+			return false
+		}
+	}
+	return isUnchanged(planned.sourcePath, dst, mode)
+}
+
+// verifyPlaced checks the checksum of a copied or cloned file when
+// verification is enabled. Links share the source and need no check.
+func (c *copier) verifyPlaced(src, dst, mode string) (string, error) {
+	if !c.verify || (mode != config.CopyModeCopy && mode != config.CopyModeClone) {
+		return StatusCopied, nil
+	}
+	if info, err := os.Lstat(dst); err != nil || !info.Mode().IsRegular() {
+		return StatusCopied, nil
+	}
+
+	want, err := fileChecksum(src)
+	if err != nil {
+		return "", fmt.Errorf("failed to verify source file: %w", err)
+	}
+	if err := verifyChecksum(dst, want); err != nil {
+		return "", err
+	}
+	return StatusVerified, nil
+}
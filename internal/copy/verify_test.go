@@ -0,0 +1,84 @@
+package copy
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/daisuke310vvv/sproutee/internal/config"
+)
+
+func TestFileSkipsIdenticalTarget(t *testing.T) {
+	tempDir := t.TempDir()
+	src := filepath.Join(tempDir, "src.txt")
+	dst := filepath.Join(tempDir, "dst.txt")
+	writeTree(t, tempDir, map[string]string{"src.txt": "same", "dst.txt": "same"})
+
+	old := time.Now().Add(-time.Hour).Truncate(time.Second)
+	if err := os.Chtimes(dst, old, old); err != nil {
+		t.Fatal(err)
+	}
+	if err := File(src, dst); err != nil {
+		t.Fatalf("File() error = %v", err)
+	}
+	if info, err := os.Stat(dst); err != nil || !info.ModTime().Equal(old) {
+		t.Errorf("File() should not rewrite an identical target: %v", err)
+	}
+}
+
+func TestFilesFromConfigStatuses(t *testing.T) {
+	tempDir := t.TempDir()
+	srcRoot := filepath.Join(tempDir, "src")
+	targetRoot := filepath.Join(tempDir, "target")
+	writeTree(t, srcRoot, map[string]string{
+		".env":      "A=1",
+		"app.tmpl":  "name={{.Name}}",
+		"link.json": "{}",
+	})
+
+	cfg := &config.Config{CopyFiles: []config.CopyEntry{
+		{From: ".env"},
+		{From: "app.tmpl", To: "app.conf", Template: true},
+		{From: "link.json", Mode: config.CopyModeHardlink},
+	}, ConflictPolicy: config.ConflictBackup}
+	opts := Options{Verify: true, Template: NewTemplateContext("feature", "", targetRoot, 1, nil)}
+
+	report := FilesFromConfigWithOptions(srcRoot, targetRoot, cfg, opts)
+	if report.SuccessCount != 3 || report.VerifiedCount != 2 || report.UnchangedCount != 0 {
+		t.Fatalf("first copy = %+v", report)
+	}
+	wantStatuses := []string{StatusVerified, StatusVerified, StatusCopied}
+	for i, want := range wantStatuses {
+		if report.Results[i].Status != want {
+			t.Errorf("Results[%d].Status = %s, want %s", i, report.Results[i].Status, want)
+		}
+	}
+
+	// Copying again leaves everything alone, without backups
+	report = FilesFromConfigWithOptions(srcRoot, targetRoot, cfg, opts)
+	if report.UnchangedCount != 3 || report.SuccessCount != 0 || report.FailureCount != 0 {
+		t.Fatalf("second copy = %+v", report)
+	}
+	for _, result := range report.Results {
+		if result.BackupPath != "" {
+			t.Errorf("unchanged file %s was backed up", result.TargetPath)
+		}
+	}
+
+	// A changed source is copied again
+	writeTree(t, srcRoot, map[string]string{".env": "A=2"})
+	report = FilesFromConfigWithOptions(srcRoot, targetRoot, cfg, opts)
+	if report.SuccessCount != 1 || report.UnchangedCount != 2 || report.Results[0].Status != StatusVerified {
+		t.Errorf("third copy = %+v", report)
+	}
+}
+
+func TestVerifyChecksumMismatch(t *testing.T) {
+	tempDir := t.TempDir()
+	writeTree(t, tempDir, map[string]string{"dst.txt": "corrupted"})
+
+	if err := verifyChecksum(filepath.Join(tempDir, "dst.txt"), contentChecksum([]byte("original"))); err == nil {
+		t.Error("verifyChecksum() should fail when the checksums differ")
+	}
+}
@@ -0,0 +1,179 @@
+package copy
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+
+	"github.com/daisuke310vvv/sproutee/internal/config"
+)
+
+// Reasons for not warming a directory.
+const (
+	WarmSkipSourceMissing   = "not installed in the source worktree"
+	WarmSkipTargetExists    = "already exists in the worktree"
+	WarmSkipLockfileMissing = "lockfile missing"
+	WarmSkipLockfileChanged = "lockfile differs"
+)
+
+// WarmResult describes how a warm_dirs entry was handled.
+type WarmResult struct {
+	Dir        string
+	SourcePath string
+	TargetPath string
+	Mode       string
+	Files      int
+	Warmed     bool
+	SkipReason string
+	Lockfile   string
+	Error      error
+}
+
+// WarmDirs copies the configured dependency directories from srcRoot to
+// targetRoot when all their lockfiles have the same checksum in both. Each
+// directory is assembled under a temporary name and renamed into place, so
+// a failure never leaves a partial directory behind.
+func WarmDirs(srcRoot, targetRoot string, cfg *config.Config) []WarmResult {
+	results := make([]WarmResult, 0, len(cfg.WarmDirs))
+	for _, warm := range cfg.WarmDirs {
+		results = append(results, warmDir(srcRoot, targetRoot, warm))
+	}
+	return results
+}
+
+func warmDir(srcRoot, targetRoot string, warm config.WarmDir) WarmResult {
+	result := WarmResult{
+		Dir:        warm.Dir,
+		SourcePath: filepath.Join(srcRoot, filepath.FromSlash(warm.Dir)),
+		TargetPath: filepath.Join(targetRoot, filepath.FromSlash(warm.Dir)),
+		Mode:       warm.Mode,
+	}
+	if result.Mode == "" {
+		result.Mode = config.CopyModeClone
+	}
+
+	if info, err := os.Stat(result.SourcePath); err != nil || !info.IsDir() {
+		result.SkipReason = WarmSkipSourceMissing
+		return result
+	}
+	if _, err := os.Lstat(result.TargetPath); err == nil {
+		result.SkipReason = WarmSkipTargetExists
+		return result
+	}
+
+	for _, lockfile := range warm.Lockfiles {
+		result.Lockfile = lockfile
+		sourceSum, err := fileChecksum(filepath.Join(srcRoot, filepath.FromSlash(lockfile)))
+		if err != nil {
+			result.SkipReason = WarmSkipLockfileMissing
+			return result
+		}
+		targetSum, err := fileChecksum(filepath.Join(targetRoot, filepath.FromSlash(lockfile)))
+		if err != nil {
+			result.SkipReason = WarmSkipLockfileMissing
+			return result
+		}
+		if sourceSum != targetSum {
+			result.SkipReason = WarmSkipLockfileChanged
+			return result
+		}
+	}
+	result.Lockfile = ""
+
+	if err := checkTarget(targetRoot, result.TargetPath); err != nil {
+		result.Error = err
+		return result
+	}
+
+	files, mode, err := copyTree(result.SourcePath, result.TargetPath, result.Mode)
+	result.Files = files
+	result.Mode = mode
+	if err != nil {
+		result.Error = err
+		return result
+	}
+	result.Warmed = true
+	return result
+}
+
+// copyTree copies the directory src to dst, which must not exist, placing
+// regular files with mode and recreating symlinks as they are. It returns
+// the number of files placed and the mode used, which is a copy when a
+// clone was not possible.
+func copyTree(src, dst, mode string) (int, string, error) {
+	if err := os.MkdirAll(filepath.Dir(dst), 0o755); err != nil {
+		return 0, mode, fmt.Errorf("failed to create target directory: %w", err)
+	}
+	tempDir, err := os.MkdirTemp(filepath.Dir(dst), tempPattern(dst))
+	if err != nil {
+		return 0, mode, fmt.Errorf("failed to create target directory: %w", err)
+	}
+
+	files := 0
+	used := mode
+	err = filepath.WalkDir(src, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		relativePath, err := filepath.Rel(src, path)
+		if err != nil {
+			return err
+		}
+		target := filepath.Join(tempDir, relativePath)
+
+		info, err := entry.Info()
+		if err != nil {
+			return err
+		}
+		switch {
+		case entry.IsDir():
+			return os.MkdirAll(target, info.Mode().Perm()|0o700)
+		case info.Mode()&os.ModeSymlink != 0:
+			linkTarget, err := os.Readlink(path)
+			if err != nil {
+				return fmt.Errorf("failed to read symlink: %w", err)
+			}
+			return os.Symlink(linkTarget, target)
+		case info.Mode().IsRegular():
+			placed, err := placeFile(path, target, mode)
+			if err != nil {
+				return err
+			}
+			if placed != mode {
+				used = placed
+			}
+			files++
+		}
+		// Sockets, pipes and devices are left out
+		return nil
+	})
+	if err == nil {
+		if info, statErr := os.Stat(src); statErr == nil {
+			_ = os.Chmod(tempDir, info.Mode().Perm())
+		}
+		err = os.Rename(tempDir, dst)
+	}
+	if err != nil {
+		_ = os.RemoveAll(tempDir)
+		return 0, used, fmt.Errorf("failed to copy directory: %w", err)
+	}
+	return files, used, nil
+}
+
+// PrintWarmSummary prints which directories were warmed and why the others
+// were skipped.
+func PrintWarmSummary(results []WarmResult) {
+	for _, result := range results {
+		switch {
+		case result.Error != nil:
+			fmt.Printf("   ❌ %s: %v\n", result.Dir, result.Error)
+		case result.Warmed:
+			fmt.Printf("   ✅ %s warmed (%d file(s), %s)\n", result.Dir, result.Files, result.Mode)
+		case result.Lockfile != "":
+			fmt.Printf("   ⏭️  %s skipped: %s (%s)\n", result.Dir, result.SkipReason, result.Lockfile)
+		default:
+			fmt.Printf("   ⏭️  %s skipped: %s\n", result.Dir, result.SkipReason)
+		}
+	}
+}
@@ -0,0 +1,73 @@
+package copy
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"github.com/daisuke310vvv/sproutee/internal/config"
+)
+
+func TestWarmDirs(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("symlinks need privileges on Windows")
+	}
+
+	tempDir := t.TempDir()
+	srcRoot := filepath.Join(tempDir, "src")
+	targetRoot := filepath.Join(tempDir, "target")
+	writeTree(t, srcRoot, map[string]string{
+		"package-lock.json":              "v1",
+		"node_modules/left-pad/index.js": "module.exports = 1",
+		"requirements.lock":              "v1",
+		".venv/bin/python":               "python",
+		"Podfile.lock":                   "v1",
+		"Pods/Manifest.lock":             "v1",
+	})
+	if err := os.Symlink("../left-pad/index.js", filepath.Join(srcRoot, "node_modules", "left-pad", "link.js")); err != nil {
+		t.Fatal(err)
+	}
+	writeTree(t, targetRoot, map[string]string{
+		"package-lock.json": "v1",
+		"requirements.lock": "v2",
+		"Pods/keep":         "existing",
+	})
+
+	cfg := &config.Config{WarmDirs: []config.WarmDir{
+		{Dir: "node_modules", Lockfiles: []string{"package-lock.json"}},
+		{Dir: ".venv", Lockfiles: []string{"requirements.lock"}, Mode: config.CopyModeHardlink},
+		{Dir: "Pods", Lockfiles: []string{"Podfile.lock"}},
+		{Dir: "vendor", Lockfiles: []string{"go.sum"}},
+	}}
+	results := WarmDirs(srcRoot, targetRoot, cfg)
+
+	want := []struct {
+		warmed bool
+		reason string
+	}{
+		{true, ""},
+		{false, WarmSkipLockfileChanged},
+		{false, WarmSkipTargetExists},
+		{false, WarmSkipSourceMissing},
+	}
+	for i, w := range want {
+		if results[i].Error != nil || results[i].Warmed != w.warmed || results[i].SkipReason != w.reason {
+			t.Errorf("WarmDirs()[%d] = %+v, want warmed=%v reason=%q", i, results[i], w.warmed, w.reason)
+		}
+	}
+	if results[0].Files != 1 {
+		t.Errorf("Files = %d, want 1", results[0].Files)
+	}
+
+	content, err := os.ReadFile(filepath.Join(targetRoot, "node_modules", "left-pad", "index.js"))
+	if err != nil || string(content) != "module.exports = 1" {
+		t.Errorf("warmed file = %q, %v", content, err)
+	}
+	if link, err := os.Readlink(filepath.Join(targetRoot, "node_modules", "left-pad", "link.js")); err != nil || link != "../left-pad/index.js" {
+		t.Errorf("warmed symlink = %q, %v, want it recreated", link, err)
+	}
+	if _, err := os.Stat(filepath.Join(targetRoot, ".venv")); !os.IsNotExist(err) {
+		t.Errorf(".venv should not be warmed: %v", err)
+	}
+}
@@ -0,0 +1,8 @@
+//go:build !linux && !darwin
+
+package copy
+
+// copyXattrs is a no-op where extended attributes are not supported.
+func copyXattrs(_, _ string) error {
+	return nil
+}
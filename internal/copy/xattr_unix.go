@@ -0,0 +1,57 @@
+//go:build linux || darwin
+
+package copy
+
+import (
+	"errors"
+	"strings"
+
+	"golang.org/x/sys/unix"
+)
+
+// copyXattrs copies the extended attributes of src to dst. File systems
+// without extended attribute support are ignored.
+func copyXattrs(src, dst string) error {
+	size, err := unix.Llistxattr(src, nil)
+	if err != nil {
+		if errors.Is(err, unix.ENOTSUP) {
+			return nil
+		}
+		return err
+	}
+	if size == 0 {
+		return nil
+	}
+
+	buf := make([]byte, size)
+	size, err = unix.Llistxattr(src, buf)
+	if err != nil {
+		return err
+	}
+
+	for _, name := range strings.Split(strings.TrimSuffix(string(buf[:size]), "\x00"), "\x00") {
+		if name == "" {
+			continue
+		}
+
+		valueSize, err := unix.Lgetxattr(src, name, nil)
+		if err != nil {
+			return err
+		}
+		value := make([]byte, valueSize)
+		valueSize, err = unix.Lgetxattr(src, name, value)
+		if err != nil {
+			return err
+		}
+
+		if err := unix.Lsetxattr(dst, name, value[:valueSize], 0); err != nil {
+			// Attributes outside the user namespace need privileges on Linux,
+			// and some system attributes cannot be set on macOS
+			if errors.Is(err, unix.EPERM) || errors.Is(err, unix.ENOTSUP) {
+				continue
+			}
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,39 @@
+//go:build linux || darwin
+
+package copy
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/daisuke310vvv/sproutee/internal/config"
+	"golang.org/x/sys/unix"
+)
+
+func TestFilesFromConfigPreserveXattrs(t *testing.T) {
+	tempDir := t.TempDir()
+	srcRoot := filepath.Join(tempDir, "src")
+	targetRoot := filepath.Join(tempDir, "target")
+	writeTree(t, srcRoot, map[string]string{"app.key": "key"})
+
+	if err := unix.Setxattr(filepath.Join(srcRoot, "app.key"), "user.sproutee.test", []byte("value"), 0); err != nil {
+		t.Skipf("extended attributes are not supported: %v", err)
+	}
+
+	cfg := &config.Config{
+		CopyFiles: config.CopyEntries("app.key"),
+		Preserve:  &config.PreserveConfig{Xattrs: true},
+	}
+	if report := FilesFromConfig(srcRoot, targetRoot, cfg); report.FailureCount != 0 {
+		t.Fatalf("FailureCount = %d, want 0: %+v", report.FailureCount, report.Results)
+	}
+
+	value := make([]byte, 64)
+	size, err := unix.Getxattr(filepath.Join(targetRoot, "app.key"), "user.sproutee.test", value)
+	if err != nil {
+		t.Fatalf("Getxattr() error = %v", err)
+	}
+	if got := string(value[:size]); got != "value" {
+		t.Errorf("xattr = %q, want %q", got, "value")
+	}
+}
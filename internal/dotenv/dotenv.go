@@ -0,0 +1,221 @@
+// Package dotenv edits .env files while keeping their layout: comments, blank
+// lines, ordering, quoting, export prefixes and multi-line values are left as
+// they are and only the values of the keys being set are rewritten.
+package dotenv
+
+import (
+	"regexp"
+	"strings"
+)
+
+var keyPattern = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_.-]*$`)
+
+// IsValidKey reports whether key can be used as a variable name.
+func IsValidKey(key string) bool {
+	return keyPattern.MatchString(key)
+}
+
+// line is a logical line of a file: a comment, a blank line, something that
+// could not be parsed, or an assignment that may span several physical lines.
+type line struct {
+	raw string
+
+	key     string
+	prefix  string
+	sep     string
+	quote   byte
+	value   string
+	comment string
+	newline string
+}
+
+// File is a parsed .env file.
+type File struct {
+	lines []line
+}
+
+// Parse reads the content of a .env file. It never fails: lines that are not
+// assignments are kept verbatim.
+func Parse(content []byte) *File {
+	physical := strings.SplitAfter(string(content), "\n")
+	if physical[len(physical)-1] == "" {
+		physical = physical[:len(physical)-1]
+	}
+
+	f := &File{}
+	for i := 0; i < len(physical); i++ {
+		l, consumed := parseAssignment(physical[i:])
+		if consumed == 0 {
+			f.lines = append(f.lines, line{raw: physical[i]})
+			continue
+		}
+		f.lines = append(f.lines, l)
+		i += consumed - 1
+	}
+	return f
+}
+
+// parseAssignment parses the assignment starting at lines[0] and returns the
+// number of physical lines it spans, or 0 when it is not an assignment.
+func parseAssignment(lines []string) (line, int) {
+	text, newline := splitNewline(lines[0])
+
+	rest := strings.TrimLeft(text, " \t")
+	if rest == "" || strings.HasPrefix(rest, "#") {
+		return line{}, 0
+	}
+	if after, ok := strings.CutPrefix(rest, "export"); ok && strings.TrimLeft(after, " \t") != after {
+		rest = strings.TrimLeft(after, " \t")
+	}
+	prefix := text[:len(text)-len(rest)]
+
+	key, value, found := strings.Cut(rest, "=")
+	if !found {
+		return line{}, 0
+	}
+	trimmedKey := strings.TrimRight(key, " \t")
+	if !IsValidKey(trimmedKey) {
+		return line{}, 0
+	}
+	trimmedValue := strings.TrimLeft(value, " \t")
+
+	l := line{
+		raw:    lines[0],
+		key:    trimmedKey,
+		prefix: prefix,
+		sep:    key[len(trimmedKey):] + "=" + value[:len(value)-len(trimmedValue)],
+	}
+
+	if trimmedValue == "" || (trimmedValue[0] != '"' && trimmedValue[0] != '\'') {
+		l.value, l.comment = cutComment(trimmedValue)
+		l.newline = newline
+		return l, 1
+	}
+
+	// Quoted values run until the closing quote, possibly on a later line
+	l.quote = trimmedValue[0]
+	body := trimmedValue[1:] + newline
+	raw := lines[0]
+	for consumed := 1; ; consumed++ {
+		if end := closingQuote(body, l.quote); end >= 0 {
+			remainder, lastNewline := splitNewline(body[end+1:])
+			l.value = unescape(body[:end], l.quote)
+			l.comment = remainder
+			l.newline = lastNewline
+			l.raw = raw
+			return l, consumed
+		}
+		if consumed == len(lines) {
+			// Unterminated quote: keep the first line as it is
+			return line{}, 0
+		}
+		body += lines[consumed]
+		raw += lines[consumed]
+	}
+}
+
+func splitNewline(s string) (string, string) {
+	switch {
+	case strings.HasSuffix(s, "\r\n"):
+		return s[:len(s)-2], "\r\n"
+	case strings.HasSuffix(s, "\n"):
+		return s[:len(s)-1], "\n"
+	default:
+		return s, ""
+	}
+}
+
+// cutComment splits an unquoted value from a trailing " # comment".
+func cutComment(value string) (string, string) {
+	for i := 1; i < len(value); i++ {
+		if value[i] == '#' && (value[i-1] == ' ' || value[i-1] == '\t') {
+			trimmed := strings.TrimRight(value[:i], " \t")
+			return trimmed, value[len(trimmed):]
+		}
+	}
+	trimmed := strings.TrimRight(value, " \t")
+	return trimmed, value[len(trimmed):]
+}
+
+// closingQuote returns the index of the quote that ends a quoted value.
+// Backslashes escape characters in double-quoted values only.
+func closingQuote(s string, quote byte) int {
+	for i := 0; i < len(s); i++ {
+		switch {
+		case quote == '"' && s[i] == '\\':
+			i++
+		case s[i] == quote:
+			return i
+		}
+	}
+	return -1
+}
+
+func unescape(s string, quote byte) string {
+	if quote != '"' {
+		return s
+	}
+	return strings.NewReplacer(`\\`, `\`, `\"`, `"`, `\n`, "\n").Replace(s)
+}
+
+// Lookup returns the value of the last assignment of key.
+func (f *File) Lookup(key string) (string, bool) {
+	value, found := "", false
+	for _, l := range f.lines {
+		if l.key == key {
+			value, found = l.value, true
+		}
+	}
+	return value, found
+}
+
+// Set replaces the value of every assignment of key, keeping its prefix,
+// quoting and comment, or appends an assignment when the key is not set.
+func (f *File) Set(key, value string) {
+	found := false
+	for i := range f.lines {
+		l := &f.lines[i]
+		if l.key != key {
+			continue
+		}
+		l.value = value
+		l.raw = l.prefix + l.key + l.sep + quote(value, l.quote) + l.comment + l.newline
+		found = true
+	}
+	if found {
+		return
+	}
+
+	if n := len(f.lines); n > 0 && !strings.HasSuffix(f.lines[n-1].raw, "\n") {
+		f.lines[n-1].raw += "\n"
+		f.lines[n-1].newline = "\n"
+	}
+	f.lines = append(f.lines, line{
+		raw:     key + "=" + quote(value, 0) + "\n",
+		key:     key,
+		sep:     "=",
+		value:   value,
+		newline: "\n",
+	})
+}
+
+// quote writes value in the quoting style of the original assignment, and
+// quotes unquoted values that would not survive being read back.
+func quote(value string, style byte) string {
+	if style == '\'' && !strings.Contains(value, "'") {
+		return "'" + value + "'"
+	}
+	if style == 0 && !strings.ContainsAny(value, " \t\r\n#\"'\\") {
+		return value
+	}
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(value) + `"`
+}
+
+// Bytes returns the content of the file.
+func (f *File) Bytes() []byte {
+	var b strings.Builder
+	for _, l := range f.lines {
+		b.WriteString(l.raw)
+	}
+	return []byte(b.String())
+}
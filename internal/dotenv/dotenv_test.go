@@ -0,0 +1,88 @@
+package dotenv
+
+import "testing"
+
+const sample = `# Database settings
+export DATABASE_URL="postgres://localhost/app" # main database
+PORT=3000
+  SECRET='s3cr3t'
+
+PRIVATE_KEY="-----BEGIN KEY-----
+abc
+-----END KEY-----"
+EMPTY=
+INVALID LINE
+`
+
+func TestParseRoundTrip(t *testing.T) {
+	if got := string(Parse([]byte(sample)).Bytes()); got != sample {
+		t.Errorf("Bytes() = %q, want the input unchanged", got)
+	}
+
+	noNewline := "A=1\r\nB=2"
+	if got := string(Parse([]byte(noNewline)).Bytes()); got != noNewline {
+		t.Errorf("Bytes() = %q, want %q", got, noNewline)
+	}
+}
+
+func TestFileLookup(t *testing.T) {
+	f := Parse([]byte(sample))
+
+	tests := map[string]string{
+		"DATABASE_URL": "postgres://localhost/app",
+		"PORT":         "3000",
+		"SECRET":       "s3cr3t",
+		"PRIVATE_KEY":  "-----BEGIN KEY-----\nabc\n-----END KEY-----",
+		"EMPTY":        "",
+	}
+	for key, want := range tests {
+		got, ok := f.Lookup(key)
+		if !ok || got != want {
+			t.Errorf("Lookup(%s) = %q, %v, want %q", key, got, ok, want)
+		}
+	}
+	if _, ok := f.Lookup("INVALID"); ok {
+		t.Error("Lookup(INVALID) should not find a value")
+	}
+}
+
+func TestFileSet(t *testing.T) {
+	f := Parse([]byte(sample))
+	f.Set("DATABASE_URL", "postgres://localhost/app_feature")
+	f.Set("PORT", "3001")
+	f.Set("SECRET", "it's")
+	f.Set("PRIVATE_KEY", "-----BEGIN KEY-----\nxyz\n-----END KEY-----")
+	f.Set("NEW_KEY", "hello world")
+
+	want := `# Database settings
+export DATABASE_URL="postgres://localhost/app_feature" # main database
+PORT=3001
+  SECRET="it's"
+
+PRIVATE_KEY="-----BEGIN KEY-----
+xyz
+-----END KEY-----"
+EMPTY=
+INVALID LINE
+NEW_KEY="hello world"
+`
+	if got := string(f.Bytes()); got != want {
+		t.Errorf("Bytes() =\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestFileSetAppendsAfterMissingNewline(t *testing.T) {
+	f := Parse([]byte("A=1"))
+	f.Set("B", "2")
+	if got := string(f.Bytes()); got != "A=1\nB=2\n" {
+		t.Errorf("Bytes() = %q, want %q", got, "A=1\nB=2\n")
+	}
+}
+
+func TestIsValidKey(t *testing.T) {
+	for key, want := range map[string]bool{"PORT": true, "_private": true, "app.name": true, "1ST": false, "A B": false, "": false} {
+		if got := IsValidKey(key); got != want {
+			t.Errorf("IsValidKey(%q) = %v, want %v", key, got, want)
+		}
+	}
+}
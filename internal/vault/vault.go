@@ -0,0 +1,406 @@
+// Package vault stores secret files encrypted with age outside the
+// repository, so that they can be decrypted into each new worktree instead
+// of being kept in plain text in every copy.
+package vault
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"filippo.io/age"
+
+	"github.com/daisuke310vvv/sproutee/internal/config"
+)
+
+const (
+	// DirName is the directory below the project's sproutee directory that
+	// holds the vault.
+	DirName = "vault"
+
+	// PassphraseEnv holds the passphrase of passphrase-protected vaults;
+	// NewPassphraseEnv holds the new one when they are rotated.
+	PassphraseEnv    = "SPROUTEE_VAULT_PASSPHRASE"
+	NewPassphraseEnv = "SPROUTEE_VAULT_NEW_PASSPHRASE"
+
+	identityFile = "identity.txt"
+	filesDir     = "files"
+	fileSuffix   = ".age"
+)
+
+// Vault is a directory of age-encrypted files. Files are encrypted to an
+// x25519 key kept in the vault directory, or with a passphrase when the
+// vault has no key and a passphrase is given.
+type Vault struct {
+	dir        string
+	passphrase string
+
+	// scryptWorkFactor overrides the cost of passphrase encryption in tests
+	scryptWorkFactor int
+}
+
+// Entry is a file stored in the vault.
+type Entry struct {
+	Path     string
+	Size     int64
+	Modified time.Time
+}
+
+// Open returns the vault in dir. The directory is created when the first
+// file is added.
+func Open(dir, passphrase string) *Vault {
+	return &Vault{dir: dir, passphrase: passphrase}
+}
+
+// Dir returns the vault directory.
+func (v *Vault) Dir() string {
+	return v.dir
+}
+
+func (v *Vault) identityPath() string {
+	return filepath.Join(v.dir, identityFile)
+}
+
+// ciphertextPath returns where the encrypted copy of a file is stored.
+func (v *Vault) ciphertextPath(relativePath string) (string, error) {
+	if relativePath == "" || config.IsExternalPath(relativePath) || config.EscapesRoot(relativePath) {
+		return "", fmt.Errorf("vault paths must be relative to the worktree: %s", relativePath)
+	}
+	cleaned := path.Clean(filepath.ToSlash(relativePath))
+	return filepath.Join(v.dir, filesDir, filepath.FromSlash(cleaned)+fileSuffix), nil
+}
+
+// usesKey reports whether the vault encrypts to its own key rather than a
+// passphrase. A new vault uses a key unless a passphrase is given.
+func (v *Vault) usesKey() bool {
+	if _, err := os.Stat(v.identityPath()); err == nil {
+		return true
+	}
+	if v.passphrase != "" {
+		return false
+	}
+	entries, err := v.List()
+	return err != nil || len(entries) == 0
+}
+
+func (v *Vault) recipient() (age.Recipient, error) {
+	if !v.usesKey() {
+		return v.scryptRecipient(v.passphrase)
+	}
+
+	identity, err := v.loadIdentity()
+	if errors.Is(err, fs.ErrNotExist) {
+		identity, err = age.GenerateX25519Identity()
+		if err != nil {
+			return nil, fmt.Errorf("failed to generate vault key: %w", err)
+		}
+		if err := writeAtomic(v.identityPath(), []byte(identity.String()+"\n")); err != nil {
+			return nil, fmt.Errorf("failed to save vault key: %w", err)
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+	return identity.Recipient(), nil
+}
+
+func (v *Vault) scryptRecipient(passphrase string) (age.Recipient, error) {
+	recipient, err := age.NewScryptRecipient(passphrase)
+	if err != nil {
+		return nil, fmt.Errorf("failed to use passphrase: %w", err)
+	}
+	if v.scryptWorkFactor > 0 {
+		recipient.SetWorkFactor(v.scryptWorkFactor)
+	}
+	return recipient, nil
+}
+
+func (v *Vault) identity() (age.Identity, error) {
+	if v.usesKey() {
+		return v.loadIdentity()
+	}
+	if v.passphrase == "" {
+		return nil, fmt.Errorf("the vault is protected by a passphrase; set %s", PassphraseEnv)
+	}
+	identity, err := age.NewScryptIdentity(v.passphrase)
+	if err != nil {
+		return nil, fmt.Errorf("failed to use passphrase: %w", err)
+	}
+	return identity, nil
+}
+
+func (v *Vault) loadIdentity() (*age.X25519Identity, error) {
+	data, err := os.ReadFile(v.identityPath())
+	if err != nil {
+		return nil, fmt.Errorf("failed to read vault key: %w", err)
+	}
+	identity, err := age.ParseX25519Identity(strings.TrimSpace(string(data)))
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse vault key: %w", err)
+	}
+	return identity, nil
+}
+
+// Add encrypts content and stores it as the file at relativePath,
+// replacing an earlier version.
+func (v *Vault) Add(relativePath string, content []byte) error {
+	target, err := v.ciphertextPath(relativePath)
+	if err != nil {
+		return err
+	}
+	recipient, err := v.recipient()
+	if err != nil {
+		return err
+	}
+
+	ciphertext, err := encrypt(content, recipient)
+	if err != nil {
+		return err
+	}
+	return writeAtomic(target, ciphertext)
+}
+
+// Decrypt returns the content of the file stored at relativePath.
+func (v *Vault) Decrypt(relativePath string) ([]byte, error) {
+	source, err := v.ciphertextPath(relativePath)
+	if err != nil {
+		return nil, err
+	}
+	identity, err := v.identity()
+	if err != nil {
+		return nil, err
+	}
+	return decryptFile(source, identity)
+}
+
+// CiphertextPath returns the encrypted file stored for relativePath.
+func (v *Vault) CiphertextPath(relativePath string) (string, error) {
+	return v.ciphertextPath(relativePath)
+}
+
+// List returns the files stored in the vault, sorted by path.
+func (v *Vault) List() ([]Entry, error) {
+	root := filepath.Join(v.dir, filesDir)
+	var entries []Entry
+	err := filepath.WalkDir(root, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if errors.Is(err, fs.ErrNotExist) && p == root {
+				return fs.SkipAll
+			}
+			return err
+		}
+		if d.IsDir() || !strings.HasSuffix(p, fileSuffix) {
+			return nil
+		}
+
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		relativePath, err := filepath.Rel(root, strings.TrimSuffix(p, fileSuffix))
+		if err != nil {
+			return err
+		}
+		entries = append(entries, Entry{Path: filepath.ToSlash(relativePath), Size: info.Size(), Modified: info.ModTime()})
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list vault: %w", err)
+	}
+
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Path < entries[j].Path })
+	return entries, nil
+}
+
+// Rotate re-encrypts every file with a new key, or with newPassphrase for
+// passphrase-protected vaults. Every file is decrypted and encrypted again
+// into a temporary file before anything is replaced, and files already
+// replaced are restored when a later one cannot be, so the vault is never
+// left with files encrypted to a key that was not saved. It returns the
+// number of files rotated.
+func (v *Vault) Rotate(newPassphrase string) (int, error) {
+	entries, err := v.List()
+	if err != nil || len(entries) == 0 {
+		return 0, err
+	}
+	identity, err := v.identity()
+	if err != nil {
+		return 0, err
+	}
+
+	usesKey := v.usesKey()
+	var recipient age.Recipient
+	var newIdentity *age.X25519Identity
+	if usesKey {
+		newIdentity, err = age.GenerateX25519Identity()
+		if err != nil {
+			return 0, fmt.Errorf("failed to generate vault key: %w", err)
+		}
+		recipient = newIdentity.Recipient()
+	} else {
+		if newPassphrase == "" {
+			return 0, fmt.Errorf("a new passphrase is required to rotate a passphrase-protected vault; set %s", NewPassphraseEnv)
+		}
+		if recipient, err = v.scryptRecipient(newPassphrase); err != nil {
+			return 0, err
+		}
+	}
+
+	var staged []stagedFile
+	discard := func() {
+		for _, file := range staged {
+			_ = os.Remove(file.tempPath)
+		}
+	}
+	for _, entry := range entries {
+		file, err := v.stageRotation(entry.Path, identity, recipient)
+		if err != nil {
+			discard()
+			return 0, err
+		}
+		staged = append(staged, file)
+	}
+	// The new key is written last and replaces the old one only after every
+	// file is encrypted to it
+	if usesKey {
+		tempPath, err := writeTemp(v.identityPath(), []byte(newIdentity.String()+"\n"))
+		if err != nil {
+			discard()
+			return 0, fmt.Errorf("failed to save vault key: %w", err)
+		}
+		staged = append(staged, stagedFile{path: v.identityPath(), tempPath: tempPath})
+	}
+
+	for i, file := range staged {
+		if err := os.Rename(file.tempPath, file.path); err != nil {
+			for _, replaced := range staged[:i] {
+				if replaced.original != nil {
+					_ = writeAtomic(replaced.path, replaced.original)
+				}
+			}
+			for _, pending := range staged[i:] {
+				_ = os.Remove(pending.tempPath)
+			}
+			return 0, fmt.Errorf("failed to replace vault file: %w", err)
+		}
+	}
+	if !usesKey {
+		v.passphrase = newPassphrase
+	}
+	return len(entries), nil
+}
+
+// stagedFile is a replacement for a vault file written to a temporary file,
+// with the content it replaces.
+type stagedFile struct {
+	path     string
+	tempPath string
+	original []byte
+}
+
+// stageRotation writes the file at relativePath, encrypted to recipient, to
+// a temporary file next to it.
+func (v *Vault) stageRotation(relativePath string, identity age.Identity, recipient age.Recipient) (stagedFile, error) {
+	source, err := v.ciphertextPath(relativePath)
+	if err != nil {
+		return stagedFile{}, err
+	}
+	original, err := os.ReadFile(source)
+	if err != nil {
+		return stagedFile{}, fmt.Errorf("failed to open vault file: %w", err)
+	}
+	content, err := decrypt(original, identity)
+	if err != nil {
+		return stagedFile{}, fmt.Errorf("%s: %w", relativePath, err)
+	}
+	ciphertext, err := encrypt(content, recipient)
+	if err != nil {
+		return stagedFile{}, err
+	}
+	tempPath, err := writeTemp(source, ciphertext)
+	if err != nil {
+		return stagedFile{}, err
+	}
+	return stagedFile{path: source, tempPath: tempPath, original: original}, nil
+}
+
+func encrypt(content []byte, recipient age.Recipient) ([]byte, error) {
+	var ciphertext bytes.Buffer
+	writer, err := age.Encrypt(&ciphertext, recipient)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encrypt file: %w", err)
+	}
+	if _, err := writer.Write(content); err != nil {
+		return nil, fmt.Errorf("failed to encrypt file: %w", err)
+	}
+	if err := writer.Close(); err != nil {
+		return nil, fmt.Errorf("failed to encrypt file: %w", err)
+	}
+	return ciphertext.Bytes(), nil
+}
+
+func decryptFile(source string, identity age.Identity) ([]byte, error) {
+	ciphertext, err := os.ReadFile(source)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open vault file: %w", err)
+	}
+	return decrypt(ciphertext, identity)
+}
+
+func decrypt(ciphertext []byte, identity age.Identity) ([]byte, error) {
+	reader, err := age.Decrypt(bytes.NewReader(ciphertext), identity)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt vault file: %w", err)
+	}
+	content, err := io.ReadAll(reader)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt vault file: %w", err)
+	}
+	return content, nil
+}
+
+// writeAtomic writes data to target with owner-only permissions through a
+// temporary file.
+func writeAtomic(target string, data []byte) error {
+	tempPath, err := writeTemp(target, data)
+	if err != nil {
+		return err
+	}
+	if err := os.Rename(tempPath, target); err != nil {
+		_ = os.Remove(tempPath)
+		return fmt.Errorf("failed to write vault file: %w", err)
+	}
+	return nil
+}
+
+// writeTemp writes data with owner-only permissions to a temporary file next
+// to target and returns its path.
+func writeTemp(target string, data []byte) (string, error) {
+	if err := os.MkdirAll(filepath.Dir(target), 0o700); err != nil {
+		return "", fmt.Errorf("failed to create vault directory: %w", err)
+	}
+	file, err := os.CreateTemp(filepath.Dir(target), "."+filepath.Base(target)+".sproutee-*")
+	if err != nil {
+		return "", fmt.Errorf("failed to write vault file: %w", err)
+	}
+	tempPath := file.Name()
+
+	if _, err := file.Write(data); err != nil {
+		_ = file.Close()
+		_ = os.Remove(tempPath)
+		return "", fmt.Errorf("failed to write vault file: %w", err)
+	}
+	if err := file.Close(); err != nil {
+		_ = os.Remove(tempPath)
+		return "", fmt.Errorf("failed to write vault file: %w", err)
+	}
+	return tempPath, nil
+}
@@ -0,0 +1,142 @@
+package vault
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestVaultAddDecryptList(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "vault")
+	v := Open(dir, "")
+
+	if entries, err := v.List(); err != nil || len(entries) != 0 {
+		t.Fatalf("List() on a new vault = %v, %v", entries, err)
+	}
+	if err := v.Add(".env", []byte("SECRET=1")); err != nil {
+		t.Fatalf("Add() error = %v", err)
+	}
+	if err := v.Add("config/master.key", []byte("key")); err != nil {
+		t.Fatalf("Add() error = %v", err)
+	}
+	if err := v.Add(".env", []byte("SECRET=2")); err != nil {
+		t.Fatalf("Add() replacing a file error = %v", err)
+	}
+	if err := v.Add("../escape", []byte("x")); err == nil {
+		t.Error("Add() should reject paths outside the worktree")
+	}
+
+	content, err := Open(dir, "").Decrypt(".env")
+	if err != nil || string(content) != "SECRET=2" {
+		t.Errorf("Decrypt() = %q, %v", content, err)
+	}
+
+	entries, err := v.List()
+	if err != nil {
+		t.Fatalf("List() error = %v", err)
+	}
+	if len(entries) != 2 || entries[0].Path != ".env" || entries[1].Path != "config/master.key" {
+		t.Errorf("List() = %+v", entries)
+	}
+
+	info, err := os.Stat(filepath.Join(dir, identityFile))
+	if err != nil || info.Mode().Perm() != 0o600 {
+		t.Errorf("vault key should be private: %v, %v", info, err)
+	}
+	ciphertext, err := os.ReadFile(filepath.Join(dir, filesDir, ".env"+fileSuffix))
+	if err != nil || string(ciphertext) == "SECRET=2" {
+		t.Errorf("file should be stored encrypted: %q, %v", ciphertext, err)
+	}
+}
+
+func TestVaultRotateKey(t *testing.T) {
+	dir := t.TempDir()
+	v := Open(dir, "")
+	if err := v.Add(".env", []byte("SECRET=1")); err != nil {
+		t.Fatal(err)
+	}
+	oldKey, err := os.ReadFile(filepath.Join(dir, identityFile))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rotated, err := v.Rotate("")
+	if err != nil || rotated != 1 {
+		t.Fatalf("Rotate() = %d, %v", rotated, err)
+	}
+	newKey, err := os.ReadFile(filepath.Join(dir, identityFile))
+	if err != nil || string(newKey) == string(oldKey) {
+		t.Errorf("Rotate() should replace the key: %v", err)
+	}
+	if content, err := v.Decrypt(".env"); err != nil || string(content) != "SECRET=1" {
+		t.Errorf("Decrypt() after Rotate() = %q, %v", content, err)
+	}
+}
+
+func TestVaultPassphrase(t *testing.T) {
+	dir := t.TempDir()
+	v := &Vault{dir: dir, passphrase: "correct horse", scryptWorkFactor: 10}
+	if err := v.Add(".env", []byte("SECRET=1")); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, identityFile)); !os.IsNotExist(err) {
+		t.Errorf("passphrase vault should not have a key: %v", err)
+	}
+
+	if _, err := Open(dir, "").Decrypt(".env"); err == nil {
+		t.Error("Decrypt() without the passphrase should fail")
+	}
+	if _, err := Open(dir, "wrong").Decrypt(".env"); err == nil {
+		t.Error("Decrypt() with a wrong passphrase should fail")
+	}
+	if _, err := v.Rotate(""); err == nil {
+		t.Error("Rotate() without a new passphrase should fail")
+	}
+
+	if _, err := v.Rotate("battery staple"); err != nil {
+		t.Fatalf("Rotate() error = %v", err)
+	}
+	if _, err := Open(dir, "correct horse").Decrypt(".env"); err == nil {
+		t.Error("Decrypt() with the old passphrase should fail after Rotate()")
+	}
+	content, err := Open(dir, "battery staple").Decrypt(".env")
+	if err != nil || string(content) != "SECRET=1" {
+		t.Errorf("Decrypt() with the new passphrase = %q, %v", content, err)
+	}
+}
+
+func TestVaultRotateFailureKeepsVault(t *testing.T) {
+	dir := t.TempDir()
+	v := Open(dir, "")
+	for _, name := range []string{"a.env", "b.env"} {
+		if err := v.Add(name, []byte("SECRET=1")); err != nil {
+			t.Fatal(err)
+		}
+	}
+	oldKey, err := os.ReadFile(filepath.Join(dir, identityFile))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, filesDir, "b.env"+fileSuffix), []byte("corrupt"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := v.Rotate(""); err == nil {
+		t.Fatal("Rotate() should fail when a file cannot be decrypted")
+	}
+	newKey, err := os.ReadFile(filepath.Join(dir, identityFile))
+	if err != nil || string(newKey) != string(oldKey) {
+		t.Errorf("failed Rotate() should keep the key: %v", err)
+	}
+	if content, err := v.Decrypt("a.env"); err != nil || string(content) != "SECRET=1" {
+		t.Errorf("Decrypt() after failed Rotate() = %q, %v", content, err)
+	}
+
+	files, err := os.ReadDir(filepath.Join(dir, filesDir))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) != 2 {
+		t.Errorf("failed Rotate() should remove its temporary files, found %d files", len(files))
+	}
+}
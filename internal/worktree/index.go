@@ -0,0 +1,71 @@
+package worktree
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// indexFileName is stored in the administrative directory Git keeps for each
+// linked worktree, so the index is released when the worktree is removed.
+const indexFileName = "sproutee-index"
+
+// WorktreeIndex returns the index of the worktree at path. The main worktree
+// has index 0; linked worktrees get the lowest free index from 1 on, which
+// stays the same for the lifetime of the worktree. It is used to give each
+// worktree its own ports.
+func (m *Manager) WorktreeIndex(path string) (int, error) {
+	gitDir, err := m.git(path, nil, "rev-parse", "--absolute-git-dir")
+	if err != nil {
+		return 0, fmt.Errorf("failed to find git directory: %w", err)
+	}
+	commonDir, err := m.git(path, nil, "rev-parse", "--git-common-dir")
+	if err != nil {
+		return 0, fmt.Errorf("failed to find git common directory: %w", err)
+	}
+	if !filepath.IsAbs(commonDir) {
+		commonDir = filepath.Join(path, commonDir)
+	}
+
+	if filepath.Clean(gitDir) == filepath.Clean(commonDir) {
+		return 0, nil
+	}
+
+	if index, ok := readIndexFile(filepath.Join(gitDir, indexFileName)); ok {
+		return index, nil
+	}
+
+	used := make(map[int]bool)
+	entries, err := os.ReadDir(filepath.Join(commonDir, "worktrees"))
+	if err != nil {
+		return 0, fmt.Errorf("failed to read worktrees: %w", err)
+	}
+	for _, entry := range entries {
+		if index, ok := readIndexFile(filepath.Join(commonDir, "worktrees", entry.Name(), indexFileName)); ok {
+			used[index] = true
+		}
+	}
+
+	index := 1
+	for used[index] {
+		index++
+	}
+	if err := os.WriteFile(filepath.Join(gitDir, indexFileName), []byte(strconv.Itoa(index)+"\n"), 0o644); err != nil {
+		return 0, fmt.Errorf("failed to store worktree index: %w", err)
+	}
+	return index, nil
+}
+
+func readIndexFile(path string) (int, bool) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return 0, false
+	}
+	index, err := strconv.Atoi(strings.TrimSpace(string(data)))
+	if err != nil || index < 1 {
+		return 0, false
+	}
+	return index, true
+}
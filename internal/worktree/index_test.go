@@ -0,0 +1,39 @@
+package worktree
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func TestManagerWorktreeIndex(t *testing.T) {
+	repo := initGitRepo(t)
+	manager := &Manager{RepoRoot: repo}
+
+	first := filepath.Join(t.TempDir(), "first")
+	second := filepath.Join(t.TempDir(), "second")
+	third := filepath.Join(t.TempDir(), "third")
+	runGit(t, repo, "worktree", "add", "-q", "-b", "first", first)
+	runGit(t, repo, "worktree", "add", "-q", "-b", "second", second)
+
+	assertIndex := func(path string, want int) {
+		t.Helper()
+		index, err := manager.WorktreeIndex(path)
+		if err != nil {
+			t.Fatalf("WorktreeIndex(%s) error = %v", path, err)
+		}
+		if index != want {
+			t.Errorf("WorktreeIndex(%s) = %d, want %d", path, index, want)
+		}
+	}
+
+	assertIndex(repo, 0)
+	assertIndex(first, 1)
+	assertIndex(second, 2)
+	assertIndex(first, 1)
+
+	// A removed worktree releases its index
+	runGit(t, repo, "worktree", "remove", first)
+	runGit(t, repo, "worktree", "add", "-q", "-b", "third", third)
+	assertIndex(third, 1)
+	assertIndex(second, 2)
+}
@@ -0,0 +1,241 @@
+package worktree
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	AgeByCommit  = "commit"
+	AgeByCreated = "created"
+)
+
+var (
+	ageSuffixes = map[string]time.Duration{
+		"d": 24 * time.Hour,
+		"w": 7 * 24 * time.Hour,
+	}
+	dirTimestampPattern = regexp.MustCompile(`_(\d{8}_\d{6})$`)
+)
+
+// Filter selects worktrees by policy. Every non-zero field must match for a
+// worktree to qualify.
+type Filter struct {
+	MergedInto string
+	Gone       bool
+	OlderThan  time.Duration
+	AgeBy      string
+	Match      string
+}
+
+func (f Filter) IsEmpty() bool {
+	return f.MergedInto == "" && !f.Gone && f.OlderThan == 0 && f.Match == ""
+}
+
+// ParseAge parses durations such as "14d", "2w" or anything accepted by
+// time.ParseDuration.
+func ParseAge(value string) (time.Duration, error) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return 0, fmt.Errorf("empty duration")
+	}
+
+	for suffix, unit := range ageSuffixes {
+		if strings.HasSuffix(value, suffix) {
+			n, err := strconv.Atoi(strings.TrimSuffix(value, suffix))
+			if err != nil || n < 0 {
+				return 0, fmt.Errorf("invalid duration: %s", value)
+			}
+			return time.Duration(n) * unit, nil
+		}
+	}
+
+	duration, err := time.ParseDuration(value)
+	if err != nil || duration < 0 {
+		return 0, fmt.Errorf("invalid duration: %s", value)
+	}
+	return duration, nil
+}
+
+// WorktreeName returns the name a worktree was created with, without the
+// timestamp suffix sproutee appends to its directory.
+func WorktreeName(worktreePath string) string {
+	return dirTimestampPattern.ReplaceAllString(filepath.Base(worktreePath), "")
+}
+
+func (m *Manager) IsBranchMerged(branch, into string) (bool, error) {
+	cmd := exec.Command("git", "merge-base", "--is-ancestor", branch, into) // #nosec G204
+	cmd.Dir = m.RepoRoot
+
+	output, err := cmd.CombinedOutput()
+	if err == nil {
+		return true, nil
+	}
+
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) && exitErr.ExitCode() == 1 {
+		return false, nil
+	}
+	return false, fmt.Errorf("failed to check merge status: %w\nOutput: %s", err, string(output))
+}
+
+func (m *Manager) IsUpstreamGone(branch string) (bool, error) {
+	cmd := exec.Command("git", "for-each-ref", "--format=%(upstream:track)", "refs/heads/"+branch) // #nosec G204
+	cmd.Dir = m.RepoRoot
+
+	output, err := cmd.CombinedOutput()
+	if err != nil {
+		return false, fmt.Errorf("failed to check upstream: %w\nOutput: %s", err, string(output))
+	}
+
+	return strings.Contains(string(output), "[gone]"), nil
+}
+
+func (m *Manager) LastCommitTime(worktreePath string) (time.Time, error) {
+	cmd := exec.Command("git", "log", "-1", "--format=%ct")
+	cmd.Dir = worktreePath
+
+	output, err := cmd.CombinedOutput()
+	if err != nil {
+		return time.Time{}, fmt.Errorf("failed to read last commit: %w\nOutput: %s", err, string(output))
+	}
+
+	seconds, err := strconv.ParseInt(strings.TrimSpace(string(output)), 10, 64)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("failed to parse commit time: %w", err)
+	}
+
+	return time.Unix(seconds, 0), nil
+}
+
+func CreationTime(worktreePath string) (time.Time, error) {
+	if match := dirTimestampPattern.FindStringSubmatch(filepath.Base(worktreePath)); match != nil {
+		if created, err := time.ParseInLocation("20060102_150405", match[1], time.Local); err == nil {
+			return created, nil
+		}
+	}
+
+	stat, err := os.Stat(filepath.Join(worktreePath, ".git"))
+	if err != nil {
+		return time.Time{}, fmt.Errorf("failed to determine creation time: %w", err)
+	}
+	return stat.ModTime(), nil
+}
+
+// Evaluate checks a worktree against the filter and returns whether it
+// qualifies together with the reasons it did.
+func (m *Manager) Evaluate(wt Info, f Filter) (bool, []string, error) {
+	var reasons []string
+
+	ref := wt.Branch
+	if ref == "" {
+		ref = wt.Commit
+	}
+
+	if f.Match != "" {
+		matched := false
+		for _, candidate := range []string{wt.Branch, WorktreeName(wt.Path), filepath.Base(wt.Path)} {
+			if ok, _ := path.Match(f.Match, candidate); ok && candidate != "" {
+				reasons = append(reasons, fmt.Sprintf("matches '%s'", f.Match))
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			return false, nil, nil
+		}
+	}
+
+	if f.MergedInto != "" {
+		merged, err := m.IsBranchMerged(ref, f.MergedInto)
+		if err != nil {
+			return false, nil, err
+		}
+		if !merged {
+			return false, nil, nil
+		}
+		reasons = append(reasons, fmt.Sprintf("merged into %s", f.MergedInto))
+	}
+
+	if f.Gone {
+		if wt.Branch == "" {
+			return false, nil, nil
+		}
+		gone, err := m.IsUpstreamGone(wt.Branch)
+		if err != nil {
+			return false, nil, err
+		}
+		if !gone {
+			return false, nil, nil
+		}
+		reasons = append(reasons, "upstream branch is gone")
+	}
+
+	if f.OlderThan > 0 {
+		var (
+			since time.Time
+			err   error
+		)
+		label := "last commit"
+		if f.AgeBy == AgeByCreated {
+			label = "created"
+			since, err = CreationTime(wt.Path)
+		} else {
+			since, err = m.LastCommitTime(wt.Path)
+		}
+		if err != nil {
+			return false, nil, err
+		}
+
+		age := time.Since(since)
+		if age < f.OlderThan {
+			return false, nil, nil
+		}
+		reasons = append(reasons, fmt.Sprintf("%s %s ago", label, formatAge(age)))
+	}
+
+	return true, reasons, nil
+}
+
+func formatAge(age time.Duration) string {
+	if days := int(age.Hours() / 24); days > 0 {
+		return fmt.Sprintf("%dd", days)
+	}
+	return age.Truncate(time.Minute).String()
+}
+
+// MatchesName reports whether name refers to the worktree by path, directory
+// name, creation name or branch.
+func (i Info) MatchesName(name string) bool {
+	if name == "" {
+		return false
+	}
+	if filepath.Clean(name) == filepath.Clean(i.Path) {
+		return true
+	}
+	return name == filepath.Base(i.Path) || name == WorktreeName(i.Path) || name == i.Branch
+}
+
+// ProtectedBy returns the first pattern that protects the worktree, matched
+// against its branch, name and directory name.
+func (i Info) ProtectedBy(patterns []string) (string, bool) {
+	for _, pattern := range patterns {
+		for _, candidate := range []string{i.Branch, WorktreeName(i.Path), filepath.Base(i.Path)} {
+			if candidate == "" {
+				continue
+			}
+			if ok, _ := path.Match(pattern, candidate); ok {
+				return pattern, true
+			}
+		}
+	}
+	return "", false
+}
@@ -0,0 +1,218 @@
+package worktree
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func initGitRepo(t *testing.T) string {
+	t.Helper()
+
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git is not available")
+	}
+
+	repo := t.TempDir()
+	runGit(t, repo, "init", "-q", "-b", "main")
+	runGit(t, repo, "config", "user.email", "test@example.com")
+	runGit(t, repo, "config", "user.name", "Test")
+	runGit(t, repo, "config", "commit.gpgsign", "false")
+
+	if err := os.WriteFile(filepath.Join(repo, "README.md"), []byte("readme"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	runGit(t, repo, "add", ".")
+	runGit(t, repo, "commit", "-q", "-m", "initial")
+
+	return repo
+}
+
+func runGit(t *testing.T, dir string, args ...string) string {
+	t.Helper()
+
+	cmd := exec.Command("git", args...)
+	cmd.Dir = dir
+	output, err := cmd.CombinedOutput()
+	if err != nil {
+		t.Fatalf("git %s failed: %v\n%s", strings.Join(args, " "), err, output)
+	}
+	return strings.TrimSpace(string(output))
+}
+
+func TestParseAge(t *testing.T) {
+	tests := []struct {
+		input   string
+		want    time.Duration
+		wantErr bool
+	}{
+		{"14d", 14 * 24 * time.Hour, false},
+		{"2w", 14 * 24 * time.Hour, false},
+		{"36h", 36 * time.Hour, false},
+		{"90m", 90 * time.Minute, false},
+		{"", 0, true},
+		{"xd", 0, true},
+		{"-1d", 0, true},
+		{"soon", 0, true},
+	}
+
+	for _, tt := range tests {
+		got, err := ParseAge(tt.input)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("ParseAge(%q) error = %v, wantErr %v", tt.input, err, tt.wantErr)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("ParseAge(%q) = %v, want %v", tt.input, got, tt.want)
+		}
+	}
+}
+
+func TestWorktreeName(t *testing.T) {
+	tests := []struct {
+		path string
+		want string
+	}{
+		{"/home/user/.sproutee/repo/feature-auth_20241212_143022", "feature-auth"},
+		{"/home/user/.sproutee/repo/my_feature_20241212_143022", "my_feature"},
+		{"/path/to/manual-worktree", "manual-worktree"},
+	}
+
+	for _, tt := range tests {
+		if got := WorktreeName(tt.path); got != tt.want {
+			t.Errorf("WorktreeName(%s) = %s, want %s", tt.path, got, tt.want)
+		}
+	}
+}
+
+func TestCreationTime(t *testing.T) {
+	created, err := CreationTime("/path/to/feature_20241212_143022")
+	if err != nil {
+		t.Fatalf("CreationTime() error = %v", err)
+	}
+
+	want := time.Date(2024, 12, 12, 14, 30, 22, 0, time.Local)
+	if !created.Equal(want) {
+		t.Errorf("CreationTime() = %v, want %v", created, want)
+	}
+
+	if _, err := CreationTime(filepath.Join(t.TempDir(), "manual")); err == nil {
+		t.Error("CreationTime() should return error without timestamp or .git file")
+	}
+}
+
+func TestFilterIsEmpty(t *testing.T) {
+	if !(Filter{AgeBy: AgeByCommit}).IsEmpty() {
+		t.Error("Filter with only AgeBy should be empty")
+	}
+	if (Filter{Gone: true}).IsEmpty() {
+		t.Error("Filter with Gone should not be empty")
+	}
+}
+
+func TestManagerEvaluate(t *testing.T) {
+	repo := initGitRepo(t)
+	manager := &Manager{RepoRoot: repo}
+
+	runGit(t, repo, "branch", "feature/merged")
+	runGit(t, repo, "checkout", "-q", "-b", "feature/open")
+	if err := os.WriteFile(filepath.Join(repo, "open.txt"), []byte("open"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	runGit(t, repo, "add", ".")
+	runGit(t, repo, "commit", "-q", "-m", "open work")
+	runGit(t, repo, "checkout", "-q", "main")
+
+	merged := Info{Path: repo, Branch: "feature/merged"}
+	open := Info{Path: repo, Branch: "feature/open"}
+
+	ok, reasons, err := manager.Evaluate(merged, Filter{MergedInto: "main", Match: "feature/*"})
+	if err != nil {
+		t.Fatalf("Evaluate() error = %v", err)
+	}
+	if !ok || len(reasons) != 2 {
+		t.Errorf("Evaluate() = %v %v, want merged branch to qualify with 2 reasons", ok, reasons)
+	}
+
+	ok, _, err = manager.Evaluate(open, Filter{MergedInto: "main"})
+	if err != nil {
+		t.Fatalf("Evaluate() error = %v", err)
+	}
+	if ok {
+		t.Error("Evaluate() should not select an unmerged branch")
+	}
+
+	ok, _, err = manager.Evaluate(merged, Filter{Match: "bugfix/*"})
+	if err != nil {
+		t.Fatalf("Evaluate() error = %v", err)
+	}
+	if ok {
+		t.Error("Evaluate() should not select a worktree that does not match")
+	}
+
+	ok, reasons, err = manager.Evaluate(open, Filter{OlderThan: time.Nanosecond, AgeBy: AgeByCommit})
+	if err != nil {
+		t.Fatalf("Evaluate() error = %v", err)
+	}
+	if !ok || !strings.Contains(reasons[0], "last commit") {
+		t.Errorf("Evaluate() = %v %v, want worktree selected by commit age", ok, reasons)
+	}
+
+	ok, _, err = manager.Evaluate(open, Filter{OlderThan: 24 * time.Hour, AgeBy: AgeByCommit})
+	if err != nil {
+		t.Fatalf("Evaluate() error = %v", err)
+	}
+	if ok {
+		t.Error("Evaluate() should not select a worktree with a recent commit")
+	}
+
+	ok, _, err = manager.Evaluate(open, Filter{Gone: true})
+	if err != nil {
+		t.Fatalf("Evaluate() error = %v", err)
+	}
+	if ok {
+		t.Error("Evaluate() should not select a branch without upstream as gone")
+	}
+}
+
+func TestInfoMatchesName(t *testing.T) {
+	info := Info{Path: "/home/user/.sproutee/repo/feature-auth_20241212_143022", Branch: "feature/auth"}
+
+	for _, name := range []string{"feature-auth", "feature-auth_20241212_143022", "feature/auth", info.Path} {
+		if !info.MatchesName(name) {
+			t.Errorf("MatchesName(%s) should be true", name)
+		}
+	}
+
+	for _, name := range []string{"", "feature", "repo"} {
+		if info.MatchesName(name) {
+			t.Errorf("MatchesName(%s) should be false", name)
+		}
+	}
+}
+
+func TestInfoProtectedBy(t *testing.T) {
+	patterns := []string{"main", "release/*", "keep-*"}
+
+	tests := []struct {
+		info    Info
+		want    string
+		protect bool
+	}{
+		{Info{Path: "/wt/main_20241212_143022", Branch: "main"}, "main", true},
+		{Info{Path: "/wt/release_20241212_143022", Branch: "release/1.2"}, "release/*", true},
+		{Info{Path: "/wt/keep-me_20241212_143022", Branch: "experiment"}, "keep-*", true},
+		{Info{Path: "/wt/feature_20241212_143022", Branch: "feature/auth"}, "", false},
+		{Info{Path: "/wt/detached_20241212_143022"}, "", false},
+	}
+
+	for _, tt := range tests {
+		pattern, protected := tt.info.ProtectedBy(patterns)
+		if protected != tt.protect || pattern != tt.want {
+			t.Errorf("ProtectedBy(%s) = %s, %v, want %s, %v", tt.info.Path, pattern, protected, tt.want, tt.protect)
+		}
+	}
+}
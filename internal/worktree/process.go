@@ -0,0 +1,69 @@
+package worktree
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// Process is a running process that uses a worktree as its working directory
+// or holds files inside it open.
+type Process struct {
+	PID     int
+	Command string
+}
+
+func (p Process) String() string {
+	return fmt.Sprintf("%s (%d)", p.Command, p.PID)
+}
+
+// FindProcessesUsing returns the processes using dir. Detection is only
+// supported on Linux; other platforms report no processes.
+func FindProcessesUsing(dir string) ([]Process, error) {
+	resolved, err := filepath.EvalSymlinks(dir)
+	if err != nil {
+		resolved = dir
+	}
+	return findProcessesUsing(filepath.Clean(resolved))
+}
+
+// TerminateProcesses sends SIGTERM to the processes and waits up to timeout
+// for them to stop using dir.
+func TerminateProcesses(dir string, processes []Process, timeout time.Duration) error {
+	for _, p := range processes {
+		proc, err := os.FindProcess(p.PID)
+		if err != nil {
+			continue
+		}
+		if err := proc.Signal(syscall.SIGTERM); err != nil && !errors.Is(err, os.ErrProcessDone) {
+			return fmt.Errorf("failed to terminate %s: %w", p, err)
+		}
+	}
+
+	deadline := time.Now().Add(timeout)
+	for {
+		remaining, err := FindProcessesUsing(dir)
+		if err != nil {
+			return err
+		}
+		if len(remaining) == 0 {
+			return nil
+		}
+		if time.Now().After(deadline) {
+			names := make([]string, len(remaining))
+			for i, p := range remaining {
+				names[i] = p.String()
+			}
+			return fmt.Errorf("processes still running: %s", strings.Join(names, ", "))
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+}
+
+func isWithin(path, dir string) bool {
+	return path == dir || strings.HasPrefix(path, dir+string(filepath.Separator))
+}
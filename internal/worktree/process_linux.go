@@ -0,0 +1,60 @@
+//go:build linux
+
+package worktree
+
+import (
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+func findProcessesUsing(dir string) ([]Process, error) {
+	entries, err := os.ReadDir("/proc")
+	if err != nil {
+		return nil, err
+	}
+
+	self := os.Getpid()
+	var processes []Process
+	for _, entry := range entries {
+		pid, err := strconv.Atoi(entry.Name())
+		if err != nil || pid == self {
+			continue
+		}
+
+		procDir := filepath.Join("/proc", entry.Name())
+		if !processUses(procDir, dir) {
+			continue
+		}
+
+		command := entry.Name()
+		if comm, err := os.ReadFile(filepath.Join(procDir, "comm")); err == nil {
+			command = strings.TrimSpace(string(comm))
+		}
+		processes = append(processes, Process{PID: pid, Command: command})
+	}
+
+	return processes, nil
+}
+
+// processUses checks the working directory and open file descriptors of a
+// process. Processes we are not allowed to inspect are ignored.
+func processUses(procDir, dir string) bool {
+	if cwd, err := os.Readlink(filepath.Join(procDir, "cwd")); err == nil && isWithin(cwd, dir) {
+		return true
+	}
+
+	fdDir := filepath.Join(procDir, "fd")
+	fds, err := os.ReadDir(fdDir)
+	if err != nil {
+		return false
+	}
+	for _, fd := range fds {
+		target, err := os.Readlink(filepath.Join(fdDir, fd.Name()))
+		if err == nil && isWithin(target, dir) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,7 @@
+//go:build !linux
+
+package worktree
+
+func findProcessesUsing(_ string) ([]Process, error) {
+	return nil, nil
+}
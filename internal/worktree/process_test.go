@@ -0,0 +1,68 @@
+package worktree
+
+import (
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"testing"
+	"time"
+)
+
+func TestIsWithin(t *testing.T) {
+	dir := filepath.Join("/tmp", "worktree")
+
+	if !isWithin(dir, dir) {
+		t.Error("isWithin() should be true for the directory itself")
+	}
+	if !isWithin(filepath.Join(dir, "src", "main.go"), dir) {
+		t.Error("isWithin() should be true for nested paths")
+	}
+	if isWithin(dir+"-other", dir) {
+		t.Error("isWithin() should be false for sibling paths with the same prefix")
+	}
+}
+
+func TestFindAndTerminateProcesses(t *testing.T) {
+	if runtime.GOOS != "linux" {
+		t.Skip("process detection is only supported on Linux")
+	}
+
+	dir := t.TempDir()
+	cmd := exec.Command("sleep", "30")
+	cmd.Dir = dir
+	if err := cmd.Start(); err != nil {
+		t.Skipf("cannot start sleep: %v", err)
+	}
+	t.Cleanup(func() { _ = cmd.Process.Kill() })
+	go func() { _ = cmd.Wait() }()
+
+	processes, err := FindProcessesUsing(dir)
+	if err != nil {
+		t.Fatalf("FindProcessesUsing() error = %v", err)
+	}
+
+	found := false
+	for _, p := range processes {
+		if p.PID == cmd.Process.Pid {
+			found = true
+			if p.Command != "sleep" {
+				t.Errorf("Process command = %s, want sleep", p.Command)
+			}
+		}
+	}
+	if !found {
+		t.Fatalf("FindProcessesUsing() = %v, want process %d", processes, cmd.Process.Pid)
+	}
+
+	if err := TerminateProcesses(dir, processes, 5*time.Second); err != nil {
+		t.Fatalf("TerminateProcesses() error = %v", err)
+	}
+
+	processes, err = FindProcessesUsing(filepath.Join(dir, "missing"))
+	if err != nil {
+		t.Fatalf("FindProcessesUsing() error = %v", err)
+	}
+	if len(processes) != 0 {
+		t.Errorf("FindProcessesUsing() = %v, want none for an unused directory", processes)
+	}
+}
@@ -0,0 +1,91 @@
+package worktree
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// Copy sources that do not name a worktree.
+const (
+	CopySourceCurrent = "current"
+	CopySourceMain    = "main"
+)
+
+// OverlayDirName is the directory below the project's sproutee directory
+// whose files are laid onto new worktrees.
+const OverlayDirName = "overlay"
+
+// ResolveCopySource returns the worktree that configured files are copied
+// from: the current worktree, the main worktree, or the worktree referred to
+// by name, branch or path.
+func (m *Manager) ResolveCopySource(source string) (string, error) {
+	if source == "" || source == CopySourceCurrent {
+		return m.RepoRoot, nil
+	}
+
+	worktrees, err := m.ListWorktrees()
+	if err != nil {
+		return "", err
+	}
+	if len(worktrees) == 0 {
+		return "", fmt.Errorf("no worktrees found")
+	}
+
+	// git lists the main worktree first
+	if source == CopySourceMain {
+		return worktrees[0].Path, nil
+	}
+
+	matches := matchWorktrees(worktrees, source)
+	if len(matches) == 0 {
+		// Paths may be relative or go through symlinks
+		if resolved, err := filepath.Abs(source); err == nil {
+			if real, err := filepath.EvalSymlinks(resolved); err == nil {
+				resolved = real
+			}
+			matches = matchWorktrees(worktrees, resolved)
+		}
+	}
+
+	switch len(matches) {
+	case 1:
+		if _, err := os.Stat(matches[0].Path); err != nil {
+			return "", fmt.Errorf("copy source worktree is missing: %s", matches[0].Path)
+		}
+		return matches[0].Path, nil
+	case 0:
+		return "", fmt.Errorf("no worktree found for copy source '%s'", source)
+	default:
+		return "", fmt.Errorf("copy source '%s' matches %d worktrees; use its path", source, len(matches))
+	}
+}
+
+func matchWorktrees(worktrees []Info, name string) []Info {
+	var matches []Info
+	for _, wt := range worktrees {
+		if wt.MatchesName(name) {
+			matches = append(matches, wt)
+		}
+	}
+	return matches
+}
+
+// ProjectDir returns ~/.sproutee/<project>, named after the main worktree
+// so that every worktree of the project shares it.
+func (m *Manager) ProjectDir() (string, error) {
+	mainPath, err := m.ResolveCopySource(CopySourceMain)
+	if err != nil {
+		return "", err
+	}
+	return (&Manager{RepoRoot: mainPath}).GetWorktreeBasePath(), nil
+}
+
+// DefaultOverlayDir returns ~/.sproutee/<project>/overlay.
+func (m *Manager) DefaultOverlayDir() (string, error) {
+	projectDir, err := m.ProjectDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(projectDir, OverlayDirName), nil
+}
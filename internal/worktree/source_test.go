@@ -0,0 +1,63 @@
+package worktree
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func TestManagerResolveCopySource(t *testing.T) {
+	repo := initGitRepo(t)
+	first := filepath.Join(t.TempDir(), "first_20250101_120000")
+	runGit(t, repo, "worktree", "add", "-q", "-b", "feature/first", first)
+	first, _ = filepath.EvalSymlinks(first)
+	repo, _ = filepath.EvalSymlinks(repo)
+
+	// Run from the linked worktree
+	manager := &Manager{RepoRoot: first}
+
+	tests := map[string]string{
+		"":                      first,
+		CopySourceCurrent:       first,
+		CopySourceMain:          repo,
+		"first":                 first,
+		"feature/first":         first,
+		"first_20250101_120000": first,
+		repo:                    repo,
+	}
+	for source, want := range tests {
+		got, err := manager.ResolveCopySource(source)
+		if err != nil {
+			t.Errorf("ResolveCopySource(%q) error = %v", source, err)
+			continue
+		}
+		if got != want {
+			t.Errorf("ResolveCopySource(%q) = %s, want %s", source, got, want)
+		}
+	}
+
+	for _, source := range []string{"missing", t.TempDir()} {
+		if _, err := manager.ResolveCopySource(source); err == nil {
+			t.Errorf("ResolveCopySource(%q) should fail", source)
+		}
+	}
+}
+
+func TestManagerDefaultOverlayDir(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+
+	repo := initGitRepo(t)
+	linked := filepath.Join(t.TempDir(), "linked")
+	runGit(t, repo, "worktree", "add", "-q", "-b", "linked", linked)
+
+	// Every worktree of the project shares the overlay of the main worktree
+	manager := &Manager{RepoRoot: linked}
+	got, err := manager.DefaultOverlayDir()
+	if err != nil {
+		t.Fatalf("DefaultOverlayDir() error = %v", err)
+	}
+	want := filepath.Join(home, SprouteeDir, filepath.Base(repo), OverlayDirName)
+	if got != want {
+		t.Errorf("DefaultOverlayDir() = %s, want %s", got, want)
+	}
+}
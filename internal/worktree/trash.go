@@ -0,0 +1,273 @@
+package worktree
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	TrashRefPrefix = "refs/sproutee/trash/"
+)
+
+// trashIdentityEnv lets archives be committed even when no Git identity is
+// configured.
+var trashIdentityEnv = []string{
+	"GIT_AUTHOR_NAME=sproutee",
+	"GIT_AUTHOR_EMAIL=sproutee@localhost",
+	"GIT_COMMITTER_NAME=sproutee",
+	"GIT_COMMITTER_EMAIL=sproutee@localhost",
+}
+
+// TrashEntry is an archive of the uncommitted work of a removed worktree. It
+// is stored as a commit under refs/sproutee/trash/<id> whose tree holds the
+// working tree (including untracked files), whose first parent is the HEAD
+// the work was based on and whose second parent holds the index.
+type TrashEntry struct {
+	ID        string
+	Commit    string
+	Name      string
+	Branch    string
+	Path      string
+	CreatedAt time.Time
+}
+
+func (m *Manager) git(dir string, env []string, args ...string) (string, error) {
+	cmd := exec.Command("git", args...) // #nosec G204
+	cmd.Dir = dir
+	if len(env) > 0 {
+		cmd.Env = append(os.Environ(), env...)
+	}
+
+	output, err := cmd.CombinedOutput()
+	if err != nil {
+		return "", fmt.Errorf("git %s failed: %w\nOutput: %s", args[0], err, string(output))
+	}
+	return strings.TrimSpace(string(output)), nil
+}
+
+// ArchiveWorktree saves staged changes, unstaged changes and untracked files
+// of the worktree under a trash ref so they can be restored later.
+func (m *Manager) ArchiveWorktree(wt Info) (*TrashEntry, error) {
+	head, err := m.git(wt.Path, nil, "rev-parse", "HEAD")
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve HEAD: %w", err)
+	}
+
+	indexTree, err := m.git(wt.Path, nil, "write-tree")
+	if err != nil {
+		return nil, fmt.Errorf("failed to save index: %w", err)
+	}
+
+	tempIndex, err := os.CreateTemp("", "sproutee-index-")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create temporary index: %w", err)
+	}
+	tempIndexPath := tempIndex.Name()
+	_ = tempIndex.Close()
+	_ = os.Remove(tempIndexPath)
+	defer os.Remove(tempIndexPath)
+
+	indexEnv := []string{"GIT_INDEX_FILE=" + tempIndexPath}
+	if _, err := m.git(wt.Path, indexEnv, "read-tree", indexTree); err != nil {
+		return nil, fmt.Errorf("failed to prepare temporary index: %w", err)
+	}
+	if _, err := m.git(wt.Path, indexEnv, "add", "-A"); err != nil {
+		return nil, fmt.Errorf("failed to collect working tree changes: %w", err)
+	}
+	worktreeTree, err := m.git(wt.Path, indexEnv, "write-tree")
+	if err != nil {
+		return nil, fmt.Errorf("failed to save working tree: %w", err)
+	}
+
+	name := WorktreeName(wt.Path)
+	indexCommit, err := m.git(wt.Path, trashIdentityEnv,
+		"commit-tree", indexTree, "-p", head, "-m", "sproutee index: "+name)
+	if err != nil {
+		return nil, fmt.Errorf("failed to commit index: %w", err)
+	}
+
+	message := fmt.Sprintf("sproutee trash: %s\n\nName: %s\nBranch: %s\nWorktree: %s\n", name, name, wt.Branch, wt.Path)
+	commit, err := m.git(wt.Path, trashIdentityEnv,
+		"commit-tree", worktreeTree, "-p", head, "-p", indexCommit, "-m", message)
+	if err != nil {
+		return nil, fmt.Errorf("failed to commit working tree: %w", err)
+	}
+
+	id := m.newTrashID(name)
+	if _, err := m.git(m.RepoRoot, nil, "update-ref", TrashRefPrefix+id, commit); err != nil {
+		return nil, fmt.Errorf("failed to store archive: %w", err)
+	}
+
+	return &TrashEntry{
+		ID:        id,
+		Commit:    commit,
+		Name:      name,
+		Branch:    wt.Branch,
+		Path:      wt.Path,
+		CreatedAt: time.Now(),
+	}, nil
+}
+
+func (m *Manager) newTrashID(name string) string {
+	id := fmt.Sprintf("%s-%s", name, time.Now().Format("20060102-150405"))
+	candidate := id
+	for i := 2; ; i++ {
+		if _, err := m.git(m.RepoRoot, nil, "rev-parse", "--verify", "--quiet", TrashRefPrefix+candidate); err != nil {
+			return candidate
+		}
+		candidate = fmt.Sprintf("%s-%d", id, i)
+	}
+}
+
+func (m *Manager) ListTrash() ([]TrashEntry, error) {
+	output, err := m.git(m.RepoRoot, nil, "for-each-ref",
+		"--format=%(refname)%00%(objectname)%00%(committerdate:unix)%00%(contents)%00", TrashRefPrefix)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list trash: %w", err)
+	}
+
+	return parseTrashList(output), nil
+}
+
+func parseTrashList(output string) []TrashEntry {
+	var entries []TrashEntry
+	for _, record := range strings.Split(output, "\x00\n") {
+		fields := strings.SplitN(strings.TrimPrefix(record, "\n"), "\x00", 4)
+		if len(fields) < 4 {
+			continue
+		}
+
+		entry := TrashEntry{
+			ID:     strings.TrimPrefix(fields[0], TrashRefPrefix),
+			Commit: fields[1],
+		}
+		if seconds, err := strconv.ParseInt(fields[2], 10, 64); err == nil {
+			entry.CreatedAt = time.Unix(seconds, 0)
+		}
+
+		for _, line := range strings.Split(strings.TrimSuffix(fields[3], "\x00"), "\n") {
+			key, value, found := strings.Cut(line, ": ")
+			if !found {
+				continue
+			}
+			switch key {
+			case "Name":
+				entry.Name = value
+			case "Branch":
+				entry.Branch = value
+			case "Worktree":
+				entry.Path = value
+			}
+		}
+
+		entries = append(entries, entry)
+	}
+
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].CreatedAt.Before(entries[j].CreatedAt)
+	})
+	return entries
+}
+
+func (m *Manager) GetTrash(id string) (*TrashEntry, error) {
+	entries, err := m.ListTrash()
+	if err != nil {
+		return nil, err
+	}
+
+	for _, entry := range entries {
+		if entry.ID == id {
+			return &entry, nil
+		}
+	}
+	return nil, fmt.Errorf("trash entry '%s' not found", id)
+}
+
+func (m *Manager) DeleteTrash(id string) error {
+	if _, err := m.git(m.RepoRoot, nil, "update-ref", "-d", TrashRefPrefix+id); err != nil {
+		return fmt.Errorf("failed to delete trash entry: %w", err)
+	}
+	return nil
+}
+
+// PurgeTrash deletes trash entries older than the retention period and
+// returns the entries it deleted.
+func (m *Manager) PurgeTrash(retention time.Duration) ([]TrashEntry, error) {
+	entries, err := m.ListTrash()
+	if err != nil {
+		return nil, err
+	}
+
+	var purged []TrashEntry
+	for _, entry := range entries {
+		if time.Since(entry.CreatedAt) < retention {
+			continue
+		}
+		if err := m.DeleteTrash(entry.ID); err != nil {
+			return purged, err
+		}
+		purged = append(purged, entry)
+	}
+	return purged, nil
+}
+
+// RestoreTrash recreates a worktree from a trash entry, with the archived
+// index, working tree changes and untracked files, and returns its path.
+func (m *Manager) RestoreTrash(id string) (string, error) {
+	entry, err := m.GetTrash(id)
+	if err != nil {
+		return "", err
+	}
+
+	base, err := m.git(m.RepoRoot, nil, "rev-parse", entry.Commit+"^1")
+	if err != nil {
+		return "", fmt.Errorf("failed to resolve archived HEAD: %w", err)
+	}
+
+	dirName, err := m.GenerateWorktreeDirName(entry.Name)
+	if err != nil {
+		return "", fmt.Errorf("failed to generate directory name: %w", err)
+	}
+
+	worktreeBasePath := m.GetWorktreeBasePath()
+	if err := os.MkdirAll(worktreeBasePath, 0o755); err != nil {
+		return "", fmt.Errorf("failed to create worktree base directory: %w", err)
+	}
+	worktreePath := filepath.Join(worktreeBasePath, dirName)
+
+	args := []string{"worktree", "add", "--detach", worktreePath, base}
+	if entry.Branch != "" {
+		checkedOut, err := m.IsBranchCheckedOut(entry.Branch)
+		if err != nil {
+			return "", err
+		}
+
+		switch {
+		case !m.BranchExists(entry.Branch):
+			args = []string{"worktree", "add", "-b", entry.Branch, worktreePath, base}
+		case !checkedOut:
+			if tip, err := m.git(m.RepoRoot, nil, "rev-parse", "refs/heads/"+entry.Branch); err == nil && tip == base {
+				args = []string{"worktree", "add", worktreePath, entry.Branch}
+			}
+		}
+	}
+
+	if _, err := m.git(m.RepoRoot, nil, args...); err != nil {
+		return "", fmt.Errorf("failed to create worktree: %w", err)
+	}
+
+	if _, err := m.git(worktreePath, nil, "read-tree", "-u", "--reset", entry.Commit+"^{tree}"); err != nil {
+		return worktreePath, fmt.Errorf("failed to restore working tree: %w", err)
+	}
+	if _, err := m.git(worktreePath, nil, "read-tree", entry.Commit+"^2^{tree}"); err != nil {
+		return worktreePath, fmt.Errorf("failed to restore index: %w", err)
+	}
+
+	return worktreePath, nil
+}
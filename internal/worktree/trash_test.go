@@ -0,0 +1,90 @@
+package worktree
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestArchiveAndRestoreWorktree(t *testing.T) {
+	repo := initGitRepo(t)
+	t.Setenv("HOME", t.TempDir())
+	manager := &Manager{RepoRoot: repo}
+
+	worktreePath := filepath.Join(t.TempDir(), "feature_20241212_143022")
+	runGit(t, repo, "worktree", "add", "-q", "-b", "feature", worktreePath)
+
+	if err := os.WriteFile(filepath.Join(worktreePath, "staged.txt"), []byte("staged"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	runGit(t, worktreePath, "add", "staged.txt")
+	if err := os.WriteFile(filepath.Join(worktreePath, "README.md"), []byte("unstaged"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(worktreePath, "untracked.txt"), []byte("untracked"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	entry, err := manager.ArchiveWorktree(Info{Path: worktreePath, Branch: "feature"})
+	if err != nil {
+		t.Fatalf("ArchiveWorktree() error = %v", err)
+	}
+	if !strings.HasPrefix(entry.ID, "feature-") {
+		t.Errorf("ArchiveWorktree() ID = %s, want feature- prefix", entry.ID)
+	}
+
+	if err := manager.ForceRemoveWorktree(worktreePath); err != nil {
+		t.Fatal(err)
+	}
+
+	entries, err := manager.ListTrash()
+	if err != nil {
+		t.Fatalf("ListTrash() error = %v", err)
+	}
+	if len(entries) != 1 || entries[0].ID != entry.ID || entries[0].Branch != "feature" || entries[0].Path != worktreePath {
+		t.Fatalf("ListTrash() = %+v, want the archived entry", entries)
+	}
+
+	restoredPath, err := manager.RestoreTrash(entry.ID)
+	if err != nil {
+		t.Fatalf("RestoreTrash() error = %v", err)
+	}
+
+	for file, want := range map[string]string{"staged.txt": "staged", "README.md": "unstaged", "untracked.txt": "untracked"} {
+		content, err := os.ReadFile(filepath.Join(restoredPath, file))
+		if err != nil {
+			t.Fatalf("restored file %s: %v", file, err)
+		}
+		if string(content) != want {
+			t.Errorf("restored %s = %s, want %s", file, content, want)
+		}
+	}
+
+	status := runGit(t, restoredPath, "status", "--porcelain")
+	for _, line := range []string{"A  staged.txt", "M README.md", "?? untracked.txt"} {
+		if !strings.Contains(status, line) {
+			t.Errorf("restored status should contain %q, got:\n%s", line, status)
+		}
+	}
+	if branch := runGit(t, restoredPath, "rev-parse", "--abbrev-ref", "HEAD"); branch != "feature" {
+		t.Errorf("restored branch = %s, want feature", branch)
+	}
+
+	purged, err := manager.PurgeTrash(time.Hour)
+	if err != nil {
+		t.Fatalf("PurgeTrash() error = %v", err)
+	}
+	if len(purged) != 0 {
+		t.Errorf("PurgeTrash() should keep recent entries, purged %d", len(purged))
+	}
+
+	purged, err = manager.PurgeTrash(0)
+	if err != nil {
+		t.Fatalf("PurgeTrash() error = %v", err)
+	}
+	if len(purged) != 1 {
+		t.Errorf("PurgeTrash() purged %d entries, want 1", len(purged))
+	}
+}
@@ -82,7 +82,7 @@ func (m *Manager) GetWorktreeBasePath() string {
 	return filepath.Join(homeDir, SprouteeDir, projectName)
 }
 
-func (m *Manager) branchExists(branch string) bool {
+func (m *Manager) BranchExists(branch string) bool {
 	cmd := exec.Command("git", "rev-parse", "--verify", branch)
 	cmd.Dir = m.RepoRoot
 	return cmd.Run() == nil
@@ -122,7 +122,7 @@ func (m *Manager) createNewBranch(branch string) error {
 }
 
 func (m *Manager) ensureBranchExists(branch string) error {
-	if m.branchExists(branch) {
+	if m.BranchExists(branch) {
 		return nil
 	}
 
@@ -177,6 +177,7 @@ type Info struct {
 	Path   string
 	Branch string
 	Commit string
+	Locked bool
 }
 
 type Status struct {
@@ -201,6 +202,12 @@ func parseWorktreeList(output string) ([]Info, error) {
 			continue
 		}
 
+		// "locked" may be given without a reason
+		if line == "locked" || strings.HasPrefix(line, "locked ") {
+			current.Locked = true
+			continue
+		}
+
 		parts := strings.SplitN(line, " ", 2)
 		if len(parts) != 2 {
 			continue
@@ -321,3 +328,59 @@ func (m *Manager) ForceRemoveWorktree(worktreePath string) error {
 
 	return nil
 }
+
+func (m *Manager) IsBranchCheckedOut(branch string) (bool, error) {
+	worktrees, err := m.ListWorktrees()
+	if err != nil {
+		return false, err
+	}
+
+	for _, wt := range worktrees {
+		if wt.Branch == branch {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func (m *Manager) DeleteBranch(branch string, force bool) error {
+	flag := "-d"
+	if force {
+		flag = "-D"
+	}
+
+	cmd := exec.Command("git", "branch", flag, branch) // #nosec G204
+	cmd.Dir = m.RepoRoot
+
+	output, err := cmd.CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("failed to delete branch: %w\nOutput: %s", err, string(output))
+	}
+
+	return nil
+}
+
+// UpstreamBranch returns the remote-tracking branch (e.g. origin/feature) the
+// branch follows, or an empty string when it has none.
+func (m *Manager) UpstreamBranch(branch string) string {
+	cmd := exec.Command("git", "rev-parse", "--abbrev-ref", "--symbolic-full-name", branch+"@{upstream}") // #nosec G204
+	cmd.Dir = m.RepoRoot
+
+	output, err := cmd.Output()
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(output))
+}
+
+func (m *Manager) DeleteRemoteTrackingBranch(remoteBranch string) error {
+	cmd := exec.Command("git", "branch", "-d", "-r", remoteBranch) // #nosec G204
+	cmd.Dir = m.RepoRoot
+
+	output, err := cmd.CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("failed to delete remote-tracking branch: %w\nOutput: %s", err, string(output))
+	}
+
+	return nil
+}
@@ -133,6 +133,7 @@ branch refs/heads/main
 worktree /path/to/feature
 HEAD abcdef1234567890
 branch refs/heads/feature-branch
+locked
 
 worktree /path/to/detached
 HEAD fedcba0987654321
@@ -163,6 +164,10 @@ HEAD fedcba0987654321
 		t.Errorf("Second worktree branch = %s, want feature-branch", worktrees[1].Branch)
 	}
 
+	if worktrees[0].Locked || !worktrees[1].Locked {
+		t.Errorf("Only the second worktree should be locked, got %v and %v", worktrees[0].Locked, worktrees[1].Locked)
+	}
+
 	if worktrees[2].Branch != "" {
 		t.Errorf("Third worktree should have empty branch for detached HEAD, got %s", worktrees[2].Branch)
 	}
@@ -267,3 +272,44 @@ func TestNewManagerWithoutGitRepo(t *testing.T) {
 		t.Error("NewManager() should return error when not in git repository")
 	}
 }
+
+func TestManagerDeleteBranch(t *testing.T) {
+	repo := initGitRepo(t)
+	manager := &Manager{RepoRoot: repo}
+
+	runGit(t, repo, "branch", "merged")
+	runGit(t, repo, "checkout", "-q", "-b", "unmerged")
+	runGit(t, repo, "commit", "-q", "--allow-empty", "-m", "work")
+	runGit(t, repo, "checkout", "-q", "main")
+
+	checkedOut, err := manager.IsBranchCheckedOut("main")
+	if err != nil {
+		t.Fatalf("IsBranchCheckedOut() error = %v", err)
+	}
+	if !checkedOut {
+		t.Error("IsBranchCheckedOut() should return true for the current branch")
+	}
+
+	checkedOut, err = manager.IsBranchCheckedOut("merged")
+	if err != nil {
+		t.Fatalf("IsBranchCheckedOut() error = %v", err)
+	}
+	if checkedOut {
+		t.Error("IsBranchCheckedOut() should return false for a branch without worktree")
+	}
+
+	if err := manager.DeleteBranch("merged", false); err != nil {
+		t.Errorf("DeleteBranch() error = %v", err)
+	}
+
+	if err := manager.DeleteBranch("unmerged", false); err == nil {
+		t.Error("DeleteBranch() without force should fail for an unmerged branch")
+	}
+	if err := manager.DeleteBranch("unmerged", true); err != nil {
+		t.Errorf("DeleteBranch() with force error = %v", err)
+	}
+
+	if upstream := manager.UpstreamBranch("main"); upstream != "" {
+		t.Errorf("UpstreamBranch() = %s, want empty for a branch without upstream", upstream)
+	}
+}